@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/google/uuid"
+)
+
+// FeedCredentials are what a private feed needs to be fetched. They are
+// stored encrypted and never returned by the API.
+type FeedCredentials struct {
+	Username    string            `json:"username,omitempty"`
+	Password    string            `json:"password,omitempty"`
+	BearerToken string            `json:"bearer_token,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ClientCert  string            `json:"client_cert,omitempty"` // PEM
+	ClientKey   string            `json:"client_key,omitempty"`  // PEM
+	CABundle    string            `json:"ca_bundle,omitempty"`   // PEM, trusted on top of the system roots
+}
+
+// reservedHeaders can't be set per feed, they're managed by the fetcher
+var reservedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"User-Agent":        true,
+	"If-None-Match":     true,
+	"If-Modified-Since": true,
+}
+
+// IsZero reports whether no credentials are set
+func (c *FeedCredentials) IsZero() bool {
+	return c == nil || (c.Username == "" && c.Password == "" && c.BearerToken == "" &&
+		len(c.Headers) == 0 && c.ClientCert == "" && c.ClientKey == "" && c.CABundle == "")
+}
+
+// Validate checks that the credentials are usable before they're stored
+func (c *FeedCredentials) Validate() error {
+	if c.IsZero() {
+		return nil
+	}
+	if c.Password != "" && c.Username == "" {
+		return errors.New("password given without a username")
+	}
+	if c.Username != "" && c.BearerToken != "" {
+		return errors.New("use either basic auth or a bearer token, not both")
+	}
+	for name, value := range c.Headers {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if name == "" || strings.ContainsAny(name, " :\r\n") || strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid header %q", name)
+		}
+		if reservedHeaders[canonical] {
+			return fmt.Errorf("header %s can't be overridden", canonical)
+		}
+		if canonical == "Authorization" && (c.Username != "" || c.BearerToken != "") {
+			return errors.New("an Authorization header conflicts with basic auth or a bearer token")
+		}
+	}
+	if _, err := c.TLSConfig(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Apply adds the auth and extra headers to a request
+func (c *FeedCredentials) Apply(req *http.Request) {
+	if c == nil {
+		return
+	}
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	}
+}
+
+// TLSConfig returns the client certificate and CA settings, nil when neither is set
+func (c *FeedCredentials) TLSConfig() (*tls.Config, error) {
+	if c == nil || (c.ClientCert == "" && c.ClientKey == "" && c.CABundle == "") {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.ClientCert != "" || c.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(c.ClientCert), []byte(c.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate or key: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(c.CABundle)) {
+			return nil, errors.New("CA bundle contains no PEM certificates")
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// ErrPrivateFeed is returned when someone other than its owner follows a feed with credentials
+var ErrPrivateFeed = errors.New("this feed is fetched with its owner's credentials, only they can follow it")
+
+// CheckCanFollow returns ErrPrivateFeed when the user may not follow the feed.
+// A feed's posts are fetched with its owner's credentials, so following one
+// would show them to anyone.
+func CheckCanFollow(ctx context.Context, db *database.Queries, feed database.Feed, userID uuid.UUID) error {
+	if feed.UserID == userID {
+		return nil
+	}
+	private, err := db.FeedHasCredentials(ctx, feed.ID)
+	if err != nil {
+		return err
+	}
+	if private {
+		return ErrPrivateFeed
+	}
+	return nil
+}
+
+// ErrFeedHasFollowers is returned when credentials are added to a feed other users follow
+var ErrFeedHasFollowers = errors.New("other users follow this feed, they would read posts fetched with your credentials")
+
+// CheckCanAddCredentials returns ErrFeedHasFollowers while anyone but the
+// feed's owner follows it, for the same reason as CheckCanFollow
+func CheckCanAddCredentials(ctx context.Context, db *database.Queries, feed database.Feed) error {
+	others, err := db.CountOtherFeedFollowers(ctx, database.CountOtherFeedFollowersParams{FeedID: feed.ID, UserID: feed.UserID})
+	if err != nil {
+		return err
+	}
+	if others > 0 {
+		return ErrFeedHasFollowers
+	}
+	return nil
+}
+
+// SaveFeedCredentials encrypts and stores a feed's credentials, removing
+// them when creds is empty
+func SaveFeedCredentials(ctx context.Context, db *database.Queries, box *SecretBox, feedID uuid.UUID, creds *FeedCredentials) error {
+	if creds.IsZero() {
+		return db.DeleteFeedCredentials(ctx, feedID)
+	}
+	if err := creds.Validate(); err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	sealed, err := box.Seal(plaintext, feedID[:])
+	if err != nil {
+		return err
+	}
+
+	return db.UpsertFeedCredentials(ctx, database.UpsertFeedCredentialsParams{
+		FeedID: feedID,
+		Secret: sealed,
+	})
+}
+
+// LoadFeedCredentials returns a feed's decrypted credentials, nil when it has none
+func LoadFeedCredentials(ctx context.Context, db *database.Queries, box *SecretBox, feedID uuid.UUID) (*FeedCredentials, error) {
+	sealed, err := db.GetFeedCredentials(ctx, feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := box.Open(sealed, feedID[:])
+	if err != nil {
+		return nil, fmt.Errorf("feed credentials: %w", err)
+	}
+
+	var creds FeedCredentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, fmt.Errorf("feed credentials: %w", err)
+	}
+	return &creds, nil
+}
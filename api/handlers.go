@@ -4,12 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/netguard"
+	"github.com/eniolaomotee/BlogGator-Go/internal/sanitize"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
@@ -18,13 +21,17 @@ type Server struct {
 	db        *database.Queries
 	router    *chi.Mux
 	jwtSecret string
+	feeds     FeedService
+	secrets   *SecretBox // nil when no encryption key is configured
 }
 
-func NewServer(db *database.Queries, secret string) *Server {
+func NewServer(db *database.Queries, secret string, feeds FeedService, secrets *SecretBox) *Server {
 	s := &Server{
 		db:        db,
 		router:    chi.NewRouter(),
 		jwtSecret: secret,
+		feeds:     feeds,
+		secrets:   secrets,
 	}
 	s.setupRoutes()
 	return s
@@ -174,21 +181,66 @@ func (s *Server) handleGetPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Attach podcast enclosures
+	postIDs := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	enclosures, err := s.db.GetEnclosuresForPosts(context.Background(), postIDs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching enclosures")
+		return
+	}
+	enclosuresByPost := make(map[uuid.UUID][]EnclosureResponse)
+	for _, enclosure := range enclosures {
+		enclosuresByPost[enclosure.PostID] = append(enclosuresByPost[enclosure.PostID], toEnclosureResponse(enclosure))
+	}
+
 	//Convert to response format
 	response := make([]PostResponse, len(posts))
 	for i, post := range posts {
+		// Bodies are sanitised at ingest; run them through again for rows stored before that
 		var desc *string
 		if post.Description.Valid {
-			desc = &post.Description.String
+			clean := sanitize.HTML(post.Description.String)
+			desc = &clean
+		}
+
+		// Prefer the extracted article over the feed's own content
+		var content *string
+		if post.ArticleHtml.Valid {
+			clean := sanitize.HTML(post.ArticleHtml.String)
+			content = &clean
+		} else if post.Content.Valid {
+			clean := sanitize.HTML(post.Content.String)
+			content = &clean
+		}
+
+		var author *string
+		if post.Author.Valid {
+			author = &post.Author.String
+		}
+
+		var revisedAt *string
+		if post.RevisedAt.Valid {
+			revised := post.RevisedAt.Time.Format(time.RFC3339)
+			revisedAt = &revised
 		}
 
 		response[i] = PostResponse{
-			ID:          post.ID.String(),
-			Title:       post.Title,
-			Url:         post.Url,
-			Description: desc,
-			PublishedAt: post.PublishedAt.Time.Format(time.RFC3339),
-			FeedName:    post.FeedName,
+			ID:           post.ID.String(),
+			Title:        post.Title,
+			Url:          post.Url,
+			Author:       author,
+			Description:  desc,
+			Content:      content,
+			WordCount:    post.WordCount,
+			ReadingTime:  post.ReadingTimeMinutes,
+			PublishedAt:  post.PublishedAt.Time.Format(time.RFC3339),
+			DateInferred: post.PublishedAtInferred,
+			FeedName:     post.FeedName,
+			RevisedAt:    revisedAt,
+			Enclosures:   enclosuresByPost[post.ID],
 		}
 	}
 
@@ -196,6 +248,67 @@ func (s *Server) handleGetPosts(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// Handle GetPostRevisions, newest first, for a post in one of the user's feeds
+func (s *Server) handleGetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextkey).(database.User)
+
+	postID, err := uuid.Parse(chi.URLParam(r, "postID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid post id")
+		return
+	}
+
+	post, err := s.db.GetPostForUser(r.Context(), database.GetPostForUserParams{ID: postID, UserID: user.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting post")
+		return
+	}
+
+	revisions, err := s.db.GetPostRevisions(r.Context(), post.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching revisions")
+		return
+	}
+
+	response := PostRevisionsResponse{
+		PostID:    post.ID.String(),
+		Title:     post.Title,
+		Revisions: make([]PostRevisionResponse, len(revisions)),
+	}
+	if post.RevisedAt.Valid {
+		revised := post.RevisedAt.Time.Format(time.RFC3339)
+		response.RevisedAt = &revised
+	}
+	for i, revision := range revisions {
+		var desc *string
+		if revision.Description.Valid {
+			desc = &revision.Description.String
+		}
+		var content *string
+		if revision.Content.Valid {
+			content = &revision.Content.String
+		}
+		changed := []string{}
+		if revision.Changed != "" {
+			changed = strings.Split(revision.Changed, ",")
+		}
+
+		response.Revisions[i] = PostRevisionResponse{
+			ReplacedAt:  revision.CreatedAt.Format(time.RFC3339),
+			Title:       revision.Title,
+			Description: desc,
+			Content:     content,
+			Changed:     changed,
+		}
+	}
+
+	respondWithJson(w, http.StatusOK, response)
+}
+
 // Handle get feeds
 func (s *Server) handleGetFeeds(w http.ResponseWriter, r *http.Request) {
 	// get user from context (set by auth middleware)
@@ -220,13 +333,90 @@ func (s *Server) handleGetFeeds(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// Handle feed health for the feeds a user follows or added
+func (s *Server) handleGetFeedHealth(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextkey).(database.User)
+
+	feeds, err := s.db.GetFeedHealthForUser(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error fetching feed health")
+		return
+	}
+
+	response := make([]FeedHealthResponse, len(feeds))
+	for i, feed := range feeds {
+		response[i] = toFeedHealthResponse(feed)
+	}
+
+	respondWithJson(w, http.StatusOK, response)
+}
+
+// Handle the fetch history of a feed the user follows or added, newest first
+func (s *Server) handleGetFeedFetches(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextkey).(database.User)
+
+	feedID, err := uuid.Parse(chi.URLParam(r, "feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid feed id")
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		fmt.Sscanf(limitStr, "%d", &limit)
+	}
+	limit = min(max(limit, 1), 500)
+
+	feed, err := s.db.GetFeedForUser(r.Context(), database.GetFeedForUserParams{ID: feedID, UserID: user.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting feed")
+		return
+	}
+
+	fetches, err := s.db.GetFeedFetches(r.Context(), database.GetFeedFetchesParams{FeedID: feed.ID, Limit: int32(limit)})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching fetch history")
+		return
+	}
+
+	response := FeedFetchesResponse{
+		FeedID:        feed.ID.String(),
+		Name:          feed.Name,
+		URL:           feed.Url,
+		LastSuccessAt: formatNullTime(feed.LastSuccessAt),
+		Fetches:       make([]FeedFetchResponse, len(fetches)),
+	}
+	for i, fetch := range fetches {
+		response.Fetches[i] = FeedFetchResponse{
+			StartedAt:  fetch.StartedAt.Format(time.RFC3339),
+			DurationMs: fetch.DurationMs,
+			Bytes:      fetch.Bytes,
+			ItemsSeen:  fetch.ItemsSeen,
+			NewPosts:   fetch.NewPosts,
+		}
+		if fetch.Status.Valid {
+			response.Fetches[i].Status = &fetch.Status.Int32
+		}
+		if fetch.Error.Valid {
+			response.Fetches[i].Error = &fetch.Error.String
+		}
+	}
+
+	respondWithJson(w, http.StatusOK, response)
+}
+
 // Handle Addfeed
 func (s *Server) handleAddFeed(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value(userContextkey).(database.User)
 
 	var req struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
+		Name        string           `json:"name"`
+		URL         string           `json:"url"`
+		Credentials *FeedCredentials `json:"credentials,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -239,22 +429,50 @@ func (s *Server) handleAddFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	creds := req.Credentials
+	if creds.IsZero() {
+		creds = nil
+	} else if !s.checkCredentials(w, creds) {
+		return
+	}
+
+	// Resolve website URLs into the feeds they advertise, taking the first one
+	candidates, err := s.feeds.DiscoverFeeds(r.Context(), req.URL, creds)
+	if errors.Is(err, netguard.ErrBlocked) {
+		respondWithError(w, http.StatusBadRequest, "URL points to a disallowed address")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "No feed found at URL")
+		return
+	}
+	feedURL := candidates[0].URL
+
 	// Create feed to add to DB
 	feed, err := s.db.CreateFeed(context.Background(), database.CreateFeedParams{
+		ID:        uuid.New(),
 		CreatedAt: time.Now().UTC(),
 		UpdatedAt: time.Now().UTC(),
 		Name:      req.Name,
-		Url:       req.URL,
+		Url:       feedURL,
 		UserID:    user.ID,
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key") {
 			respondWithError(w, http.StatusConflict, "Feed already exists")
+			return
 		}
 		respondWithError(w, http.StatusInternalServerError, "unable to create feed")
 		return
 	}
 
+	if creds != nil {
+		if err := SaveFeedCredentials(r.Context(), s.db, s.secrets, feed.ID, creds); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error saving feed credentials")
+			return
+		}
+	}
+
 	// Auto-follow feed
 	_, err = s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
 		UserID: user.ID,
@@ -265,13 +483,117 @@ func (s *Server) handleAddFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithJson(w, http.StatusOK, FeedResponse{
-		ID:        feed.ID.String(),
-		Name:      feed.Name,
-		URL:       feed.Url,
-		CreatedAt: feed.CreatedAt.Format(time.RFC3339),
+	response := FeedResponse{
+		ID:            feed.ID.String(),
+		Name:          feed.Name,
+		URL:           feed.Url,
+		CreatedAt:     feed.CreatedAt.Format(time.RFC3339),
+		Authenticated: creds != nil,
+	}
+	// Offer the other feeds the site advertises so the client can switch
+	if len(candidates) > 1 {
+		response.Candidates = candidates
+	}
+
+	respondWithJson(w, http.StatusOK, response)
+
+}
+
+// checkCredentials validates credentials from a request, answering with an error when they can't be stored
+func (s *Server) checkCredentials(w http.ResponseWriter, creds *FeedCredentials) bool {
+	if s.secrets == nil {
+		respondWithError(w, http.StatusNotImplemented, "Feed credentials need "+EncryptionKeyEnv+" to be set on the server")
+		return false
+	}
+	if err := creds.Validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid credentials: %s", err))
+		return false
+	}
+	return true
+}
+
+// ownedFeed loads the feed in the URL, answering with an error unless the user added it
+func (s *Server) ownedFeed(w http.ResponseWriter, r *http.Request, user database.User) (database.Feed, bool) {
+	feedID, err := uuid.Parse(chi.URLParam(r, "feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid feed id")
+		return database.Feed{}, false
+	}
+
+	feed, err := s.db.GetFeedByID(r.Context(), feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Feed not found")
+		return database.Feed{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting feed")
+		return database.Feed{}, false
+	}
+	if feed.UserID != user.ID {
+		respondWithError(w, http.StatusForbidden, "Only the user who added a feed can change its credentials")
+		return database.Feed{}, false
+	}
+	return feed, true
+}
+
+// Handle SetFeedCredentials, replacing any stored credentials
+func (s *Server) handleSetFeedCredentials(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextkey).(database.User)
+
+	feed, ok := s.ownedFeed(w, r, user)
+	if !ok {
+		return
+	}
+
+	var creds FeedCredentials
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if creds.IsZero() {
+		respondWithError(w, http.StatusBadRequest, "No credentials given, use DELETE to remove them")
+		return
+	}
+	if !s.checkCredentials(w, &creds) {
+		return
+	}
+	err := CheckCanAddCredentials(r.Context(), s.db, feed)
+	if errors.Is(err, ErrFeedHasFollowers) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving feed credentials")
+		return
+	}
+
+	if err := SaveFeedCredentials(r.Context(), s.db, s.secrets, feed.ID, &creds); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving feed credentials")
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, map[string]string{
+		"message": "Feed credentials saved",
 	})
+}
+
+// Handle DeleteFeedCredentials
+func (s *Server) handleDeleteFeedCredentials(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextkey).(database.User)
 
+	feed, ok := s.ownedFeed(w, r, user)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteFeedCredentials(r.Context(), feed.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error removing feed credentials")
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, map[string]string{
+		"message": "Feed credentials removed",
+	})
 }
 
 // Handle FollowFeed
@@ -293,6 +615,25 @@ func (s *Server) handleFollowFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	feed, err := s.db.GetFeedByID(r.Context(), feedId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting feed")
+		return
+	}
+	err = CheckCanFollow(r.Context(), s.db, feed, user.ID)
+	if errors.Is(err, ErrPrivateFeed) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error following feed")
+		return
+	}
+
 	_, err = s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
 		UserID: user.ID,
 		FeedID: feedId,
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
 )
 
 func respondWithJson(w http.ResponseWriter, code int, payload interface{}) {
@@ -16,3 +20,60 @@ func respondWithJson(w http.ResponseWriter, code int, payload interface{}) {
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJson(w, code, ErrorResponse{Error: message})
 }
+
+func toEnclosureResponse(enclosure database.Enclosure) EnclosureResponse {
+	response := EnclosureResponse{
+		URL:      enclosure.Url,
+		MimeType: enclosure.MimeType,
+	}
+	if enclosure.LengthBytes.Valid {
+		response.LengthBytes = &enclosure.LengthBytes.Int64
+	}
+	if enclosure.DurationSeconds.Valid {
+		response.DurationSeconds = &enclosure.DurationSeconds.Int32
+	}
+	return response
+}
+
+// FeedStatus summarises a feed's health as ok, failing, disabled or pending
+func FeedStatus(feed database.Feed) string {
+	switch {
+	case feed.DisabledAt.Valid:
+		return "disabled"
+	case feed.ConsecutiveFailures > 0:
+		return "failing"
+	case !feed.LastFetchedAt.Valid:
+		return "pending"
+	default:
+		return "ok"
+	}
+}
+
+func toFeedHealthResponse(feed database.Feed) FeedHealthResponse {
+	response := FeedHealthResponse{
+		ID:                  feed.ID.String(),
+		Name:                feed.Name,
+		URL:                 feed.Url,
+		Status:              FeedStatus(feed),
+		ConsecutiveFailures: feed.ConsecutiveFailures,
+		LastFetchedAt:       formatNullTime(feed.LastFetchedAt),
+		LastSuccessAt:       formatNullTime(feed.LastSuccessAt),
+		NextFetchAt:         formatNullTime(feed.NextFetchAt),
+		DisabledAt:          formatNullTime(feed.DisabledAt),
+	}
+	if feed.LastStatus.Valid {
+		response.LastStatus = &feed.LastStatus.Int32
+	}
+	if feed.LastError.Valid {
+		response.LastError = &feed.LastError.String
+	}
+	return response
+}
+
+func formatNullTime(t sql.NullTime) *string {
+	if !t.Valid {
+		return nil
+	}
+	formatted := t.Time.Format(time.RFC3339)
+	return &formatted
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// RefreshLimitError is returned when a user asks for another refresh too soon
+type RefreshLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RefreshLimitError) Error() string {
+	return fmt.Sprintf("refreshed too recently, try again in %s", e.RetryAfter)
+}
+
+// RefreshResult is what an on-demand refresh did for one feed
+type RefreshResult struct {
+	FeedID       string `json:"feed_id"`
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	NewPosts     int    `json:"new_posts"`
+	UpdatedPosts int    `json:"updated_posts"`
+	Skipped      string `json:"skipped,omitempty"` // why the feed wasn't fetched
+	Error        string `json:"error,omitempty"`
+}
+
+// Handle an on-demand refresh of a feed the user follows or added
+func (s *Server) handleRefreshFeed(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userContextkey).(database.User)
+
+	feedID, err := uuid.Parse(chi.URLParam(r, "feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid feed id")
+		return
+	}
+
+	feed, err := s.db.GetFeedForUser(r.Context(), database.GetFeedForUserParams{ID: feedID, UserID: user.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting feed")
+		return
+	}
+
+	results, err := s.feeds.RefreshFeeds(r.Context(), user.ID, []database.Feed{feed})
+	var limitErr *RefreshLimitError
+	if errors.As(err, &limitErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(limitErr.RetryAfter.Seconds())))
+		respondWithError(w, http.StatusTooManyRequests, limitErr.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error refreshing feed")
+		return
+	}
+
+	result := results[0]
+	switch {
+	case result.Skipped != "":
+		respondWithJson(w, http.StatusConflict, result)
+	case result.Error != "":
+		respondWithJson(w, http.StatusBadGateway, result)
+	default:
+		respondWithJson(w, http.StatusOK, result)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -12,6 +13,7 @@ import (
 func (s *Server) setupRoutes() {
 	// Global middleware
 	s.router.Use(middleware.Logger)
+	s.router.Use(metrics.Middleware) // outside Recoverer, so panics count as 500s
 	s.router.Use(middleware.Recoverer)
 	s.router.Use(CORSMiddleware)
 
@@ -19,6 +21,10 @@ func (s *Server) setupRoutes() {
 	s.router.Post("/api/register", s.handleRegister)
 	s.router.Post("/api/login", s.handleLogin)
 
+	// WebSub hubs call back here, they are authenticated by the subscription's secret
+	s.router.Get("/api/websub/{feedID}", s.handleWebSubCallback)
+	s.router.Post("/api/websub/{feedID}", s.handleWebSubCallback)
+
 	//Health check
 	s.router.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
 		respondWithJson(w, 200, map[string]string{
@@ -26,18 +32,27 @@ func (s *Server) setupRoutes() {
 		})
 	})
 
+	// Prometheus scrape endpoint
+	s.router.Method(http.MethodGet, "/metrics", metrics.Handler())
+
 	//Protected Routes
 	s.router.Group(func(r chi.Router) {
 		r.Use(s.AuthMiddleware)
 
 		// Posts
 		r.Get("/api/posts", s.handleGetPosts)
+		r.Get("/api/posts/{postID}/revisions", s.handleGetPostRevisions)
 
 		// Feeds
 		r.Get("/api/feeds", s.handleGetFeeds)
+		r.Get("/api/feeds/health", s.handleGetFeedHealth)
+		r.Get("/api/feeds/{feedID}/fetches", s.handleGetFeedFetches)
+		r.Post("/api/feeds/{feedID}/refresh", s.handleRefreshFeed)
 		r.Post("/api/feeds", s.handleAddFeed)
 		r.Post("/api/feeds/follow", s.handleFollowFeed)
 		r.Delete("/api/feeds/{feedID}/unfollow", s.handleUnfollowFeed)
+		r.Put("/api/feeds/{feedID}/credentials", s.handleSetFeedCredentials)
+		r.Delete("/api/feeds/{feedID}/credentials", s.handleDeleteFeedCredentials)
 
 		// User Info
 		r.Get("/api/me", s.handleGetcurrentUser)
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// EncryptionKeyEnv names the environment variable holding the server key used
+// to encrypt secrets at rest, 32 random bytes in base64 (openssl rand -base64 32)
+const EncryptionKeyEnv = "GATOR_ENCRYPTION_KEY"
+
+// secretVersion prefixes sealed values so the format can change later
+const secretVersion byte = 1
+
+var ErrNoEncryptionKey = errors.New(EncryptionKeyEnv + " env variable missing")
+
+// SecretBox encrypts secrets with AES-256-GCM under the server key
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox creates a SecretBox from a base64 encoded 32 byte key
+func NewSecretBox(key string) (*SecretBox, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// SecretBoxFromEnv reads the key from GATOR_ENCRYPTION_KEY, returning nil when it isn't set
+func SecretBoxFromEnv() (*SecretBox, error) {
+	key := os.Getenv(EncryptionKeyEnv)
+	if key == "" {
+		return nil, nil
+	}
+	return NewSecretBox(key)
+}
+
+// Seal encrypts plaintext. associated is authenticated but not stored, binding
+// the ciphertext to its owner so it can't be copied onto another row.
+func (b *SecretBox) Seal(plaintext, associated []byte) ([]byte, error) {
+	if b == nil {
+		return nil, ErrNoEncryptionKey
+	}
+
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{secretVersion}, nonce...)
+	return b.aead.Seal(out, nonce, plaintext, associated), nil
+}
+
+// Open decrypts a value produced by Seal with the same associated data
+func (b *SecretBox) Open(sealed, associated []byte) ([]byte, error) {
+	if b == nil {
+		return nil, ErrNoEncryptionKey
+	}
+
+	nonceSize := b.aead.NonceSize()
+	if len(sealed) < 1+nonceSize || sealed[0] != secretVersion {
+		return nil, errors.New("unrecognised secret format")
+	}
+
+	plaintext, err := b.aead.Open(nil, sealed[1:1+nonceSize], sealed[1+nonceSize:], associated)
+	if err != nil {
+		return nil, errors.New("couldn't decrypt secret, was the encryption key changed?")
+	}
+	return plaintext, nil
+}
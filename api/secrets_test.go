@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func newTestBox(t *testing.T) *SecretBox {
+	t.Helper()
+	key := make([]byte, 32)
+	rand.Read(key)
+	box, err := NewSecretBox(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatalf("Error creating secret box: %s", err)
+	}
+	return box
+}
+
+func TestSecretBox(t *testing.T) {
+	box := newTestBox(t)
+	feedID := uuid.New()
+	plaintext := []byte(`{"username":"alice","password":"hunter2"}`)
+
+	sealed, err := box.Seal(plaintext, feedID[:])
+	if err != nil {
+		t.Fatalf("Error sealing: %s", err)
+	}
+	if bytes.Contains(sealed, []byte("hunter2")) {
+		t.Fatalf("Expected the sealed value not to contain the plaintext")
+	}
+
+	opened, err := box.Open(sealed, feedID[:])
+	if err != nil {
+		t.Fatalf("Error opening: %s", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Fatalf("Expected %s, got %s", plaintext, opened)
+	}
+
+	// Bound to the feed it was sealed for
+	otherFeed := uuid.New()
+	if _, err := box.Open(sealed, otherFeed[:]); err == nil {
+		t.Fatalf("Expected opening with another feed's ID to fail")
+	}
+
+	// And to the key
+	if _, err := newTestBox(t).Open(sealed, feedID[:]); err == nil {
+		t.Fatalf("Expected opening with another key to fail")
+	}
+}
+
+func TestWebSubSecret(t *testing.T) {
+	box := newTestBox(t)
+	feedID := uuid.New()
+
+	sealed, err := SealWebSubSecret(box, feedID, "hub-secret")
+	if err != nil {
+		t.Fatalf("Error sealing: %s", err)
+	}
+	secret, err := OpenWebSubSecret(box, feedID, sealed)
+	if err != nil || secret != "hub-secret" {
+		t.Fatalf("Expected hub-secret, got %q, %v", secret, err)
+	}
+
+	// Not interchangeable with the feed's credentials
+	if _, err := box.Open(sealed, feedID[:]); err == nil {
+		t.Fatalf("Expected a websub secret not to open as feed credentials")
+	}
+}
+
+func TestNewSecretBoxRejectsBadKeys(t *testing.T) {
+	tests := []string{
+		"not base64!",
+		base64.StdEncoding.EncodeToString([]byte("too short")),
+	}
+	for _, key := range tests {
+		if _, err := NewSecretBox(key); err == nil {
+			t.Fatalf("Expected an error for key %q", key)
+		}
+	}
+}
+
+func TestFeedCredentialsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		creds   FeedCredentials
+		wantErr bool
+	}{
+		{name: "empty", creds: FeedCredentials{}},
+		{name: "basic auth", creds: FeedCredentials{Username: "alice", Password: "secret"}},
+		{name: "bearer token", creds: FeedCredentials{BearerToken: "abc"}},
+		{name: "custom header", creds: FeedCredentials{Headers: map[string]string{"PRIVATE-TOKEN": "abc"}}},
+		{name: "password without user", creds: FeedCredentials{Password: "secret"}, wantErr: true},
+		{name: "basic and bearer", creds: FeedCredentials{Username: "alice", BearerToken: "abc"}, wantErr: true},
+		{name: "reserved header", creds: FeedCredentials{Headers: map[string]string{"host": "internal"}}, wantErr: true},
+		{name: "header injection", creds: FeedCredentials{Headers: map[string]string{"X-Token": "a\r\nHost: evil"}}, wantErr: true},
+		{name: "bad certificate", creds: FeedCredentials{ClientCert: "nope", ClientKey: "nope"}, wantErr: true},
+		{name: "bad CA bundle", creds: FeedCredentials{CABundle: "nope"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.creds.Validate()
+			if test.wantErr && err == nil {
+				t.Fatalf("Expected an error, got nil")
+			}
+			if !test.wantErr && err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package api
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"context"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
 
 // Request/ Response Types
 type RegisterRequest struct {
@@ -24,19 +30,100 @@ type ErrorResponse struct {
 }
 
 type PostResponse struct {
-	ID          string  `json:"id"`
-	Title       string  `json:"title"`
-	Url         string  `json:"url"`
-	Description *string `json:"description"`
-	PublishedAt string  `json:"published_at"`
-	FeedName    string  `json:"feed_name"`
+	ID           string              `json:"id"`
+	Title        string              `json:"title"`
+	Url          string              `json:"url"`
+	Author       *string             `json:"author,omitempty"`
+	Description  *string             `json:"description"`
+	Content      *string             `json:"content,omitempty"`
+	WordCount    int32               `json:"word_count"`
+	ReadingTime  int32               `json:"reading_time_minutes"`
+	PublishedAt  string              `json:"published_at"`
+	DateInferred bool                `json:"date_inferred"`
+	FeedName     string              `json:"feed_name"`
+	RevisedAt    *string             `json:"revised_at,omitempty"`
+	Enclosures   []EnclosureResponse `json:"enclosures,omitempty"`
+}
+
+// PostRevisionResponse is a previous version of a post. Changed lists the
+// fields that differ in the version that replaced it.
+type PostRevisionResponse struct {
+	ReplacedAt  string   `json:"replaced_at"`
+	Title       string   `json:"title"`
+	Description *string  `json:"description"`
+	Content     *string  `json:"content,omitempty"`
+	Changed     []string `json:"changed"`
+}
+
+type PostRevisionsResponse struct {
+	PostID    string                 `json:"post_id"`
+	Title     string                 `json:"title"`
+	RevisedAt *string                `json:"revised_at,omitempty"`
+	Revisions []PostRevisionResponse `json:"revisions"`
+}
+
+type EnclosureResponse struct {
+	URL             string `json:"url"`
+	MimeType        string `json:"mime_type"`
+	LengthBytes     *int64 `json:"length_bytes,omitempty"`
+	DurationSeconds *int32 `json:"duration_seconds,omitempty"`
 }
 
 type FeedResponse struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	URL       string `json:"url"`
-	CreatedAt string `json:"created_at"`
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	URL           string          `json:"url"`
+	CreatedAt     string          `json:"created_at"`
+	Authenticated bool            `json:"authenticated,omitempty"`
+	Candidates    []FeedCandidate `json:"candidates,omitempty"`
+}
+
+type FeedHealthResponse struct {
+	ID                  string  `json:"id"`
+	Name                string  `json:"name"`
+	URL                 string  `json:"url"`
+	Status              string  `json:"status"`
+	ConsecutiveFailures int32   `json:"consecutive_failures"`
+	LastStatus          *int32  `json:"last_status,omitempty"`
+	LastError           *string `json:"last_error,omitempty"`
+	LastFetchedAt       *string `json:"last_fetched_at,omitempty"`
+	LastSuccessAt       *string `json:"last_success_at,omitempty"`
+	NextFetchAt         *string `json:"next_fetch_at,omitempty"`
+	DisabledAt          *string `json:"disabled_at,omitempty"`
+}
+
+type FeedFetchResponse struct {
+	StartedAt  string  `json:"started_at"`
+	DurationMs int32   `json:"duration_ms"`
+	Status     *int32  `json:"status,omitempty"`
+	Bytes      int64   `json:"bytes"`
+	ItemsSeen  int32   `json:"items_seen"`
+	NewPosts   int32   `json:"new_posts"`
+	Error      *string `json:"error,omitempty"`
+}
+
+type FeedFetchesResponse struct {
+	FeedID        string              `json:"feed_id"`
+	Name          string              `json:"name"`
+	URL           string              `json:"url"`
+	LastSuccessAt *string             `json:"last_success_at,omitempty"`
+	Fetches       []FeedFetchResponse `json:"fetches"`
+}
+
+// FeedCandidate is a feed discovered from a website URL
+type FeedCandidate struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// FeedService exposes feed fetching and ingestion, which live outside this package, to the handlers
+type FeedService interface {
+	DiscoverFeeds(ctx context.Context, pageURL string, creds *FeedCredentials) ([]FeedCandidate, error)
+	// IngestPush stores the items of a document a WebSub hub pushed for the feed
+	IngestPush(ctx context.Context, feedID uuid.UUID, body []byte, contentType string) error
+	// RefreshFeeds fetches the feeds now for the user, or returns a *RefreshLimitError
+	RefreshFeeds(ctx context.Context, userID uuid.UUID, feeds []database.Feed) ([]RefreshResult, error)
 }
 
 type Request struct {
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/websub"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// webSubStore gives the WebSub callback the subscriptions kept in the
+// database. Callback ids are feed IDs.
+type webSubStore struct {
+	db      *database.Queries
+	secrets *SecretBox
+}
+
+// webSubAssociated binds a sealed secret to its feed's subscription, so it
+// can't be swapped with the feed's credentials
+func webSubAssociated(feedID uuid.UUID) []byte {
+	return append([]byte("websub:"), feedID[:]...)
+}
+
+// SealWebSubSecret encrypts a subscription's secret for storage
+func SealWebSubSecret(box *SecretBox, feedID uuid.UUID, secret string) ([]byte, error) {
+	return box.Seal([]byte(secret), webSubAssociated(feedID))
+}
+
+// OpenWebSubSecret decrypts a secret sealed by SealWebSubSecret
+func OpenWebSubSecret(box *SecretBox, feedID uuid.UUID, sealed []byte) (string, error) {
+	secret, err := box.Open(sealed, webSubAssociated(feedID))
+	if err != nil {
+		return "", fmt.Errorf("websub secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+func (s webSubStore) Subscription(ctx context.Context, id string) (websub.Subscription, error) {
+	feedID, err := uuid.Parse(id)
+	if err != nil {
+		return websub.Subscription{}, websub.ErrNotFound
+	}
+	sub, err := s.db.GetWebSubSubscription(ctx, feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return websub.Subscription{}, websub.ErrNotFound
+	}
+	if err != nil {
+		return websub.Subscription{}, err
+	}
+	secret, err := OpenWebSubSecret(s.secrets, feedID, sub.Secret)
+	if err != nil {
+		return websub.Subscription{}, err
+	}
+	return websub.Subscription{Topic: sub.TopicUrl, Secret: secret, Pending: sub.State == "pending"}, nil
+}
+
+func (s webSubStore) Verified(ctx context.Context, id string, lease time.Duration) error {
+	return s.db.ActivateWebSubSubscription(ctx, database.ActivateWebSubSubscriptionParams{
+		FeedID:       uuid.MustParse(id), // already parsed by Subscription
+		LeaseSeconds: int32(lease / time.Second),
+	})
+}
+
+func (s webSubStore) Denied(ctx context.Context, id string, reason string) error {
+	return s.db.SetWebSubSubscriptionState(ctx, database.SetWebSubSubscriptionStateParams{
+		FeedID:    uuid.MustParse(id),
+		State:     "denied",
+		LastError: sql.NullString{String: reason, Valid: reason != ""},
+	})
+}
+
+// Handle WebSub callbacks: hubs verify subscriptions with GET and push content with POST
+func (s *Server) handleWebSubCallback(w http.ResponseWriter, r *http.Request) {
+	handler := websub.Handler{
+		Store: webSubStore{db: s.db, secrets: s.secrets},
+		Deliver: func(ctx context.Context, id string, body []byte, contentType string) error {
+			return s.feeds.IngestPush(ctx, uuid.MustParse(id), body, contentType)
+		},
+	}
+	handler.ServeCallback(w, r, chi.URLParam(r, "feedID"))
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"github.com/eniolaomotee/BlogGator-Go/api"
 	"github.com/eniolaomotee/BlogGator-Go/internal/config"
-	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"log"
@@ -30,12 +32,25 @@ func main() {
 
 	defer db.Close()
 
-	dbQueries := database.New(db)
+	dbQueries := metrics.Queries(db)
+
+	policy, err := cfg.FetchPolicy()
+	if err != nil {
+		log.Fatalf("error reading fetch settings %v", err)
+	}
+
+	secrets, err := api.SecretBoxFromEnv()
+	if err != nil {
+		log.Fatalf("error reading %s %v", api.EncryptionKeyEnv, err)
+	}
 
 	//Build State
 	state := &config.State{
-		Db:   dbQueries,
-		Conf: &cfg,
+		Db:      dbQueries,
+		Conf:    &cfg,
+		Conn:    db,
+		Fetcher: fetch.New(policy),
+		Secrets: secrets,
 	}
 	// Build Command Registry
 	cmds := &config.Commands{}
@@ -44,16 +59,20 @@ func main() {
 	cmds.Register("register", config.ArgumentValidationMiddleware(config.RegisterHandler, 1))
 	cmds.Register("reset", config.ResetHandler)
 	cmds.Register("users", config.GetAllUsersHandler)
-	cmds.Register("agg", config.MiddlewareLoggedIn(config.AggregatorService))
+	cmds.Register("agg", config.AggregatorService)
 	cmds.Register("feeds", config.GetAllFeeds)
+	cmds.Register("feed", config.MiddlewareLoggedIn(config.FeedHandler))
+	cmds.Register("refresh", config.MiddlewareLoggedIn(config.RefreshHandler))
 	cmds.Register("follow", config.ArgumentValidationMiddleware(config.MiddlewareLoggedIn(config.FollowHandler), 1))
-	cmds.Register("addfeed", config.ArgumentValidationMiddleware(config.MiddlewareLoggedIn(config.AddFeedHandler), 2))
+	cmds.Register("addfeed", config.MiddlewareLoggedIn(config.AddFeedHandler))
 	cmds.Register("following", config.MiddlewareLoggedIn(config.FeedFollowingHandler))
 	cmds.Register("unfollow", config.ArgumentValidationMiddleware(config.MiddlewareLoggedIn(config.UnfollowHandler), 1))
 	cmds.Register("browse", config.MiddlewareLoggedIn(config.BrowseHandler))
 	cmds.Register("user", config.MiddlewareLoggedIn(config.CurrentUserHandler))
 	cmds.Register("search", config.MiddlewareLoggedIn(config.SearchHandler))
 	cmds.Register("tui", config.MiddlewareLoggedIn(config.TUIHandler))
+	cmds.Register("read", config.MiddlewareLoggedIn(config.ReadHandler))
+	cmds.Register("podcasts", config.MiddlewareLoggedIn(config.PodcastsHandler))
 	cmds.Register("serve", config.ServeHandler)
 	cmds.Register("service", config.MiddlewareLoggedIn(config.ServiceManagerHandler))
 
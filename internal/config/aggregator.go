@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -11,26 +12,97 @@ import (
 	"time"
 
 	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
+	"github.com/google/uuid"
 )
 
-func AggregatorService(s *State, cmd Command, user database.User) error {
+const (
+	numWorkers = 5
+	batchSize  = 10 // Fetch multiple feeds per tick
 
-	if len(cmd.Args) < 1 {
-		return fmt.Errorf("usage : agg <time_between_reqs>, e.g 'agg 1m' ")
+	// feedLease is how long a claimed feed stays reserved for this instance.
+	// It covers the wait in the queue and the fetch; when an instance dies
+	// with feeds claimed, other instances pick them up after this long.
+	feedLease = 10 * time.Minute
+)
+
+// aggregatorID names this aggregator instance in the leases it takes
+func aggregatorID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
 	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8])
+}
 
-	time_between_reqs := cmd.Args[0]
-	timeBetweenRequest, err := time.ParseDuration(time_between_reqs)
+// feedLeaser hands out feed leases. *database.Queries implements it; tests
+// use an in-memory version.
+type feedLeaser interface {
+	ClaimFeedsToFetch(ctx context.Context, arg database.ClaimFeedsToFetchParams) ([]database.Feed, error)
+	ReleaseFeedLease(ctx context.Context, arg database.ReleaseFeedLeaseParams) error
+	ReleaseFeedLeases(ctx context.Context, owner string) error
+}
+
+// claimFeeds leases up to limit due feeds to this instance, so other
+// instances running at the same time skip them. Feeds in exclude aren't claimed.
+func claimFeeds(ctx context.Context, db feedLeaser, owner string, limit int, exclude []uuid.UUID) ([]database.Feed, error) {
+	if exclude == nil {
+		exclude = []uuid.UUID{} // a nil slice is NULL, which would exclude everything
+	}
+	return db.ClaimFeedsToFetch(ctx, database.ClaimFeedsToFetchParams{
+		Owner:        owner,
+		LeaseSeconds: int32(feedLease / time.Second),
+		Exclude:      exclude,
+		BatchSize:    int32(limit),
+	})
+}
+
+// releaseFeed gives up the lease on a feed, once it has been rescheduled or skipped
+func releaseFeed(db feedLeaser, owner string, feed database.Feed) {
+	err := db.ReleaseFeedLease(context.Background(), database.ReleaseFeedLeaseParams{ID: feed.ID, Owner: owner})
 	if err != nil {
-		return fmt.Errorf("invalid duration :%s", err)
+		log.Printf("couldn't release lease on feed %s: %s", feed.Name, err)
 	}
+}
 
-	const numWorkers = 5
-	const batchSize = 10 // Fetch multiple feeds per tick
+// releaseFeeds gives up every lease this instance holds, for feeds still queued at shutdown
+func releaseFeeds(db feedLeaser, owner string) {
+	if err := db.ReleaseFeedLeases(context.Background(), owner); err != nil {
+		log.Printf("couldn't release feed leases: %s", err)
+	}
+}
+
+// feedResult is what a worker reports for each feed it processes
+type feedResult struct {
+	Feed     database.Feed
+	Stored   ingestResult
+	Err      error
+	Duration time.Duration
+}
+
+// Aggregation is global, so no user needs to be logged in
+func AggregatorService(s *State, cmd Command) error {
+	flags, err := ParseAggFlags(cmd.Args)
+	if err != nil {
+		return err
+	}
+	if flags.Once {
+		return aggregateOnce(s, flags)
+	}
+	timeBetweenRequest := flags.Interval
+	owner := aggregatorID()
+
+	metricsAddr := flags.MetricsAddr
+	if metricsAddr == "" && s.Conf != nil {
+		metricsAddr = s.Conf.MetricsAddr
+	}
+	if metricsAddr != "" {
+		metrics.Serve(metricsAddr)
+	}
 
 	//Channels
 	feedChan := make(chan database.Feed, batchSize) // buffered channel
-	errChan := make(chan error, numWorkers)         // Collect error from workers
+	results := make(chan feedResult, numWorkers)    // Collect results from workers
 	doneChan := make(chan struct{})                 // Signal shutdown
 
 	//Waitgroup to track worker goroutines
@@ -43,20 +115,24 @@ func AggregatorService(s *State, cmd Command, user database.User) error {
 	// Start worker pool
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
-		go worker(ctx, i, s, feedChan, errChan, &wg)
+		go worker(ctx, i, s, owner, feedChan, results, &wg)
 	}
 
 	// Error collection goroutine
 	go func() {
-		for err := range errChan {
-			log.Printf("Worker err : %v", err)
+		for result := range results {
+			if result.Err != nil {
+				log.Printf("Worker err : failed to scrape %s:%v", result.Feed.Name, result.Err)
+			}
 		}
 	}()
 
-	log.Printf("Starting aggregator: %d workers, fetching every %s", numWorkers, timeBetweenRequest)
+	log.Printf("Starting aggregator %s: %d workers, fetching every %s", owner, numWorkers, timeBetweenRequest)
 
 	// Feed immediately on start
-	fetchBatch(ctx, s, feedChan, batchSize)
+	renewWebSubSubscriptions(ctx, s)
+	pruneFetchHistory(ctx, s)
+	fetchBatch(ctx, s, owner, feedChan, batchSize)
 
 	ticker := time.NewTicker(timeBetweenRequest)
 	defer ticker.Stop()
@@ -69,14 +145,17 @@ func AggregatorService(s *State, cmd Command, user database.User) error {
 	for {
 		select {
 		case <-ticker.C:
-			fetchBatch(ctx, s, feedChan, batchSize)
+			renewWebSubSubscriptions(ctx, s)
+			pruneFetchHistory(ctx, s)
+			fetchBatch(ctx, s, owner, feedChan, batchSize)
 
 		case <-sigChan:
 			log.Println("Shutdown signal received, cleaning up...")
 			cancel()        // cancel context
 			close(feedChan) // close feed channel
 			wg.Wait()       // wait for workers to finish, blocks until all workers become 0
-			close(errChan)  // close error channel
+			close(results)  // close results channel
+			releaseFeeds(s.Db, owner)
 			log.Println("Aggregator stopped gracefully")
 			return nil
 
@@ -88,7 +167,7 @@ func AggregatorService(s *State, cmd Command, user database.User) error {
 }
 
 // workers processes feeds from channel
-func worker(ctx context.Context, id int, s *State, feedChan <-chan database.Feed, errChan chan<- error, wg *sync.WaitGroup) {
+func worker(ctx context.Context, id int, s *State, owner string, feedChan <-chan database.Feed, results chan<- feedResult, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	log.Printf("[worker %d] started", id)
@@ -103,30 +182,32 @@ func worker(ctx context.Context, id int, s *State, feedChan <-chan database.Feed
 				log.Printf("[worker %d] channel close, exiting", id)
 				return
 			}
+			metrics.WorkerQueueDepth.Set(float64(len(feedChan)))
 			log.Printf("[worker %d] Processing feed: %s", id, feed.Name)
 
 			start := time.Now()
 
-			if err := ScrapeFeeds(s, feed); err != nil {
-				errChan <- fmt.Errorf("[worker %d ] failed to scrape %s:%w", id, feed.Name, err)
-			} else {
-				duration := time.Since(start)
+			stored, err := scrapeFeed(s, feed)
+			duration := time.Since(start)
+			releaseFeed(s.Db, owner, feed)
+			if err == nil {
 				log.Printf("[Worker %d] completed %s in %v", id, feed.Name, duration)
 			}
+			results <- feedResult{Feed: feed, Stored: stored, Err: err, Duration: duration}
 		}
 	}
 }
 
-// fetchBatch fetches multiple feeds  and sends them to workers
-func fetchBatch(ctx context.Context, s *State, feedChan chan<- database.Feed, batchSize int32) {
-	feeds, err := s.Db.GetNextFeedToFetch(ctx, batchSize)
+// fetchBatch claims multiple feeds and sends them to workers
+func fetchBatch(ctx context.Context, s *State, owner string, feedChan chan<- database.Feed, batchSize int) {
+	feeds, err := claimFeeds(ctx, s.Db, owner, batchSize, nil)
 	if err != nil {
 		log.Printf("error fetching feeds %v", err)
 		return
 	}
 
 	if len(feeds) == 0 {
-		log.Printf("no feeds available to fetch")
+		log.Printf("no feeds due for fetching")
 		return
 	}
 
@@ -135,9 +216,122 @@ func fetchBatch(ctx context.Context, s *State, feedChan chan<- database.Feed, ba
 		select {
 		case feedChan <- feed:
 			// Feed sent successfully, for workers to process
+			metrics.WorkerQueueDepth.Set(float64(len(feedChan)))
 		case <-ctx.Done():
 			log.Printf("Context cancelled while queuing for feeds")
 			return
 		}
 	}
 }
+
+// aggSummary is what `agg --once` reports
+type aggSummary struct {
+	Feeds           int          `json:"feeds_fetched"`
+	NewPosts        int          `json:"new_posts"`
+	UpdatedPosts    int          `json:"updated_posts"`
+	Failures        int          `json:"failures"`
+	Failed          []aggFailure `json:"failed,omitempty"`
+	DurationSeconds float64      `json:"duration_seconds"`
+}
+
+type aggFailure struct {
+	Feed  string `json:"feed"`
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+func (summary *aggSummary) add(result feedResult) {
+	summary.Feeds++
+	if result.Err != nil {
+		summary.Failures++
+		summary.Failed = append(summary.Failed, aggFailure{
+			Feed:  result.Feed.Name,
+			URL:   result.Feed.Url,
+			Error: result.Err.Error(),
+		})
+		return
+	}
+	summary.NewPosts += result.Stored.New
+	summary.UpdatedPosts += result.Stored.Updated
+}
+
+func (summary *aggSummary) print(asJSON bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Fetched %d feeds in %.1fs: %d new posts, %d updated, %d failed\n",
+		summary.Feeds, summary.DurationSeconds, summary.NewPosts, summary.UpdatedPosts, summary.Failures)
+	for _, failure := range summary.Failed {
+		fmt.Printf("  ✗ %s (%s): %s\n", failure.Feed, failure.URL, failure.Error)
+	}
+	return nil
+}
+
+// aggregateOnce fetches every feed that is due exactly once with the worker
+// pool, prints a summary and fails when more feeds failed than allowed
+func aggregateOnce(s *State, flags *AggFlags) error {
+	ctx := context.Background()
+	start := time.Now()
+	owner := aggregatorID()
+	defer releaseFeeds(s.Db, owner)
+
+	feedChan := make(chan database.Feed, batchSize)
+	results := make(chan feedResult, batchSize)
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go worker(ctx, i, s, owner, feedChan, results, &wg)
+	}
+
+	renewWebSubSubscriptions(ctx, s)
+	pruneFetchHistory(ctx, s)
+
+	// Work in batches, waiting for each before asking for the next so feeds
+	// just fetched are rescheduled first. A feed that couldn't be rescheduled
+	// is still due, so feeds already fetched are excluded from the next claim.
+	var summary aggSummary
+	var seen []uuid.UUID
+	var claimErr error
+	for {
+		feeds, err := claimFeeds(ctx, s.Db, owner, batchSize, seen)
+		if err != nil {
+			claimErr = fmt.Errorf("error fetching feeds: %w", err)
+			break
+		}
+		if len(feeds) == 0 {
+			break
+		}
+
+		for _, feed := range feeds {
+			seen = append(seen, feed.ID)
+			feedChan <- feed
+			metrics.WorkerQueueDepth.Set(float64(len(feedChan)))
+		}
+		for range feeds {
+			summary.add(<-results)
+		}
+	}
+
+	close(feedChan)
+	wg.Wait()
+
+	// Report what was fetched even when claiming more feeds failed
+	summary.DurationSeconds = time.Since(start).Seconds()
+	if err := summary.print(flags.JSON); err != nil {
+		return err
+	}
+	if claimErr != nil {
+		return claimErr
+	}
+
+	if summary.Failures > flags.MaxFailures {
+		return fmt.Errorf("%d feeds failed, more than the %d allowed by --max-failures", summary.Failures, flags.MaxFailures)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestParseAggFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    AggFlags
+		wantErr bool
+	}{
+		{name: "interval", args: []string{"1m"}, want: AggFlags{Interval: time.Minute}},
+		{name: "once", args: []string{"--once"}, want: AggFlags{Once: true}},
+		{name: "once with options", args: []string{"--once", "--json", "--max-failures=3"}, want: AggFlags{Once: true, JSON: true, MaxFailures: 3}},
+		{name: "max failures as separate arg", args: []string{"--max-failures", "2", "--once"}, want: AggFlags{Once: true, MaxFailures: 2}},
+		{name: "metrics address", args: []string{"5m", "--metrics-addr", ":9101"}, want: AggFlags{Interval: 5 * time.Minute, MetricsAddr: ":9101"}},
+		{name: "missing interval", args: []string{}, wantErr: true},
+		{name: "bad interval", args: []string{"soon"}, wantErr: true},
+		{name: "negative interval", args: []string{"-1m"}, wantErr: true},
+		{name: "once with interval", args: []string{"--once", "1m"}, wantErr: true},
+		{name: "once with metrics address", args: []string{"--once", "--metrics-addr=:9101"}, wantErr: true},
+		{name: "json without once", args: []string{"1m", "--json"}, wantErr: true},
+		{name: "negative max failures", args: []string{"--once", "--max-failures", "-1"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flags, err := ParseAggFlags(test.args)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %+v", flags)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if *flags != test.want {
+				t.Fatalf("Expected %+v, got %+v", test.want, *flags)
+			}
+		})
+	}
+}
+
+func TestAggSummary(t *testing.T) {
+	var summary aggSummary
+	summary.add(feedResult{Feed: database.Feed{Name: "Go Blog"}, Stored: ingestResult{New: 3, Updated: 1, Unchanged: 10}})
+	summary.add(feedResult{Feed: database.Feed{Name: "Quiet"}, Stored: ingestResult{Unchanged: 5}})
+	summary.add(feedResult{Feed: database.Feed{Name: "Broken", Url: "https://broken.example.com/feed"}, Err: errors.New("unexpected status: 500")})
+
+	if summary.Feeds != 3 || summary.NewPosts != 3 || summary.UpdatedPosts != 1 || summary.Failures != 1 {
+		t.Fatalf("Unexpected summary: %+v", summary)
+	}
+	if len(summary.Failed) != 1 || summary.Failed[0].Feed != "Broken" || summary.Failed[0].Error != "unexpected status: 500" {
+		t.Fatalf("Expected the failure to be listed, got %+v", summary.Failed)
+	}
+}
+
+func TestAggregatorID(t *testing.T) {
+	host, _ := os.Hostname()
+	first, second := aggregatorID(), aggregatorID()
+	if first == second {
+		t.Fatalf("Expected instances to get different ids, got %q twice", first)
+	}
+	if !strings.HasPrefix(first, fmt.Sprintf("%s-%d-", host, os.Getpid())) {
+		t.Fatalf("Expected the id to name the host and process, got %q", first)
+	}
+}
+
+// memLeases is an in-memory feedLeaser following the lease queries in
+// sql/queries/feeds.sql, with a clock the test moves forward
+type memLeases struct {
+	now   time.Time
+	feeds []database.Feed
+}
+
+func (m *memLeases) ClaimFeedsToFetch(ctx context.Context, arg database.ClaimFeedsToFetchParams) ([]database.Feed, error) {
+	if arg.Exclude == nil {
+		return nil, nil // id <> ALL(NULL) is never true
+	}
+
+	var claimed []database.Feed
+	for i := range m.feeds {
+		feed := &m.feeds[i]
+		if len(claimed) == int(arg.BatchSize) {
+			break
+		}
+		if feed.DisabledAt.Valid ||
+			(feed.NextFetchAt.Valid && feed.NextFetchAt.Time.After(m.now)) ||
+			(feed.LeaseExpiresAt.Valid && feed.LeaseExpiresAt.Time.After(m.now)) ||
+			slices.Contains(arg.Exclude, feed.ID) {
+			continue
+		}
+		feed.LeaseOwner = sql.NullString{String: arg.Owner, Valid: true}
+		feed.LeaseExpiresAt = sql.NullTime{Time: m.now.Add(time.Duration(arg.LeaseSeconds) * time.Second), Valid: true}
+		claimed = append(claimed, *feed)
+	}
+	return claimed, nil
+}
+
+func (m *memLeases) ReleaseFeedLease(ctx context.Context, arg database.ReleaseFeedLeaseParams) error {
+	for i := range m.feeds {
+		if m.feeds[i].ID == arg.ID && m.feeds[i].LeaseOwner.String == arg.Owner {
+			m.feeds[i].LeaseOwner = sql.NullString{}
+			m.feeds[i].LeaseExpiresAt = sql.NullTime{}
+		}
+	}
+	return nil
+}
+
+func (m *memLeases) ReleaseFeedLeases(ctx context.Context, owner string) error {
+	for i := range m.feeds {
+		if m.feeds[i].LeaseOwner.String == owner {
+			m.feeds[i].LeaseOwner = sql.NullString{}
+			m.feeds[i].LeaseExpiresAt = sql.NullTime{}
+		}
+	}
+	return nil
+}
+
+func feedNames(feeds []database.Feed) string {
+	var names []string
+	for _, feed := range feeds {
+		names = append(names, feed.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestFeedLeases(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	db := &memLeases{now: now}
+	for _, name := range []string{"a", "b", "c"} {
+		db.feeds = append(db.feeds, database.Feed{ID: uuid.New(), Name: name})
+	}
+	db.feeds = append(db.feeds, database.Feed{ID: uuid.New(), Name: "not due", NextFetchAt: sql.NullTime{Time: now.Add(time.Hour), Valid: true}})
+	a, b := db.feeds[0], db.feeds[1]
+	ctx := context.Background()
+
+	claim := func(owner string, limit int, exclude []uuid.UUID) string {
+		t.Helper()
+		feeds, err := claimFeeds(ctx, db, owner, limit, exclude)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return feedNames(feeds)
+	}
+
+	// Instances claiming one after the other never get the same feed
+	if got := claim("one", 2, nil); got != "a,b" {
+		t.Fatalf("Expected the first instance to claim a,b, got %q", got)
+	}
+	if got := claim("two", 10, nil); got != "c" {
+		t.Fatalf("Expected the second instance to claim only c, got %q", got)
+	}
+	if got := claim("three", 10, nil); got != "" {
+		t.Fatalf("Expected nothing left to claim, got %q", got)
+	}
+
+	// Only the owner can release a lease
+	releaseFeed(db, "two", a)
+	if got := claim("three", 10, nil); got != "" {
+		t.Fatalf("Expected another instance's release to be ignored, got %q", got)
+	}
+	releaseFeed(db, "one", a)
+	if got := claim("three", 10, nil); got != "a" {
+		t.Fatalf("Expected the released feed to be claimable, got %q", got)
+	}
+
+	// Excluded feeds stay unclaimed even when free
+	releaseFeeds(db, "one")
+	if got := claim("two", 10, []uuid.UUID{b.ID}); got != "" {
+		t.Fatalf("Expected the excluded feed to be skipped, got %q", got)
+	}
+	if got := claim("two", 10, nil); got != "b" {
+		t.Fatalf("Expected the feed to be claimable without the exclusion, got %q", got)
+	}
+
+	// Leases held by an instance that died expire after feedLease
+	db.now = now.Add(feedLease - time.Second)
+	if got := claim("four", 10, nil); got != "" {
+		t.Fatalf("Expected leases to hold until they expire, got %q", got)
+	}
+	db.now = now.Add(feedLease + time.Second)
+	if got := claim("four", 10, nil); got != "a,b,c" {
+		t.Fatalf("Expected expired leases to be claimable, got %q", got)
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import "strings"
+
+// atomToRSS maps an Atom feed onto the RSS model that collectFeed stores
+func atomToRSS(atom *AtomFeed) *RSSFeed {
+	var rss RSSFeed
+
+	rss.Channel.Title = atom.Title
+	rss.Channel.Description = atom.Subtitle
+	rss.Channel.Link = atomAlternateLink(atom.Links)
+	rss.Channel.UpdatePeriod = atom.UpdatePeriod
+	rss.Channel.UpdateFrequency = atom.UpdateFrequency
+	for _, link := range atom.Links {
+		if link.Rel == "hub" || link.Rel == "self" {
+			rss.Channel.AtomLinks = append(rss.Channel.AtomLinks, link)
+		}
+	}
+
+	for _, entry := range atom.Entries {
+		// Prefer the summary as the description, falling back to the full content
+		description := entry.Summary.value()
+		if description == "" {
+			description = entry.Content.value()
+		}
+
+		rss.Channel.Item = append(rss.Channel.Item, RSSItem{
+			Title:       strings.TrimSpace(entry.Title),
+			Link:        atomAlternateLink(entry.Links),
+			Description: description,
+			Content:     entry.Content.value(),
+			PubDate:     strings.TrimSpace(entry.Published),
+			Updated:     strings.TrimSpace(entry.Updated),
+			GUID:        strings.TrimSpace(entry.ID),
+			Author:      atomAuthor(entry.Authors),
+			Enclosures:  atomEnclosures(entry.Links),
+		})
+	}
+
+	return &rss
+}
+
+// atomAlternateLink picks the link pointing at the HTML version of the entry
+func atomAlternateLink(links []AtomLink) string {
+	fallback := ""
+	for _, link := range links {
+		// A link without rel is an alternate link per RFC 4287
+		if link.Rel != "" && link.Rel != "alternate" {
+			continue
+		}
+		if link.Type == "" || strings.Contains(link.Type, "html") {
+			return link.Href
+		}
+		if fallback == "" {
+			fallback = link.Href
+		}
+	}
+	return fallback
+}
+
+// atomEnclosures returns the entry's rel="enclosure" links
+func atomEnclosures(links []AtomLink) []RSSEnclosure {
+	var enclosures []RSSEnclosure
+	for _, link := range links {
+		if link.Rel == "enclosure" && link.Href != "" {
+			enclosures = append(enclosures, RSSEnclosure{URL: link.Href, Type: link.Type, Length: link.Length})
+		}
+	}
+	return enclosures
+}
+
+// atomAuthor joins the names of the entry's authors
+func atomAuthor(authors []AtomPerson) string {
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// value returns the text of the construct, keeping markup for xhtml content
+func (t AtomText) value() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+var xmlEncodingPattern = regexp.MustCompile(`^\s*<\?xml[^>]*?encoding\s*=\s*["']([A-Za-z0-9._:\-]+)["']`)
+
+// toUTF8 converts a feed body to UTF-8. The charset comes from, in order of
+// precedence, a byte order mark, the HTTP Content-Type header and the XML
+// declaration; without any of them the body is assumed to be UTF-8 already.
+func toUTF8(data []byte, contentType string) ([]byte, error) {
+	label := feedCharset(data, contentType)
+	if label == "" {
+		return data, nil
+	}
+
+	encoding, name := charset.Lookup(label)
+	if encoding == nil {
+		return nil, fmt.Errorf("unsupported charset %q", label)
+	}
+	if name == "utf-8" {
+		return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), nil
+	}
+
+	converted, err := encoding.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s body: %w", name, err)
+	}
+	return converted, nil
+}
+
+// feedCharset returns the charset label declared for the body, or "" when none is declared
+func feedCharset(data []byte, contentType string) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xef\xbb\xbf")):
+		return "utf-8"
+	case bytes.HasPrefix(data, []byte("\xfe\xff")):
+		return "utf-16be"
+	case bytes.HasPrefix(data, []byte("\xff\xfe")):
+		return "utf-16le"
+	}
+
+	if contentType != "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			if label := strings.TrimSpace(params["charset"]); label != "" {
+				return label
+			}
+		}
+	}
+
+	if match := xmlEncodingPattern.FindSubmatch(data); match != nil {
+		return string(match[1])
+	}
+
+	return ""
+}
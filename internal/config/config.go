@@ -15,6 +15,8 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/eniolaomotee/BlogGator-Go/api"
 	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
+	"github.com/eniolaomotee/BlogGator-Go/internal/sanitize"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 )
@@ -33,19 +35,26 @@ func ServeHandler(s *State, cmd Command) error {
 		log.Fatalf("SECRET KEY env variable missing")
 	}
 
-	server := api.NewServer(s.Db, jwtSecret)
+	server := api.NewServer(s.Db, jwtSecret, &feedService{s: s}, s.Secrets)
 
 	log.Printf(" Starting HTTP API server on port %s", port)
 	log.Printf(" API Documentation:")
 	log.Printf("   POST   /api/register       - Register new user")
 	log.Printf("   POST   /api/login          - Login")
 	log.Printf("   GET    /api/posts          - Get posts (auth required)")
+	log.Printf("   GET    /api/posts/{id}/revisions - Get a post's previous versions (auth required)")
 	log.Printf("   GET    /api/feeds          - Get feeds (auth required)")
 	log.Printf("   POST   /api/feeds          - Add feed (auth required)")
 	log.Printf("   POST   /api/feeds/follow   - Follow feed (auth required)")
 	log.Printf("   DELETE /api/feeds/{id}/unfollow - Unfollow feed (auth required)")
+	log.Printf("   GET    /api/feeds/{id}/fetches - Get a feed's fetch history (auth required)")
+	log.Printf("   POST   /api/feeds/{id}/refresh - Fetch a feed now (auth required)")
+	log.Printf("   PUT    /api/feeds/{id}/credentials - Set feed credentials (auth required)")
+	log.Printf("   DELETE /api/feeds/{id}/credentials - Remove feed credentials (auth required)")
 	log.Printf("   GET    /api/me             - Get current user (auth required)")
 	log.Printf("   GET    /api/health         - Health check")
+	log.Printf("   GET    /metrics            - Prometheus metrics")
+	log.Printf("   GET    /api/websub/{id}    - WebSub callback, for hubs (POST too)")
 
 	addr := fmt.Sprintf(":%s", port)
 	if err := http.ListenAndServe(addr, server); err != nil {
@@ -244,8 +253,30 @@ func CurrentUserHandler(s *State, cmd Command, user database.User) error {
 
 func AddFeedHandler(s *State, cmd Command, user database.User) error {
 
-	Name := cmd.Args[0]
-	UrlP := cmd.Args[1]
+	flags, err := ParseAddFeedFlags(cmd.Args)
+	if err != nil {
+		return err
+	}
+	Name := flags.Name
+
+	creds, err := addFeedCredentials(flags)
+	if err != nil {
+		return err
+	}
+	if creds != nil && s.Secrets == nil {
+		return fmt.Errorf("set %s to store feed credentials", api.EncryptionKeyEnv)
+	}
+
+	// Resolve website URLs into the feeds they advertise
+	candidates, err := discoverFeeds(context.Background(), s.Fetcher, flags.URL, creds)
+	if err != nil {
+		return fmt.Errorf("couldn't find a feed at %s: %w", flags.URL, err)
+	}
+
+	UrlP, err := chooseFeedCandidate(candidates, flags.Pick)
+	if err != nil {
+		return err
+	}
 
 	feed, err := s.Db.CreateFeed(context.Background(), database.CreateFeedParams{
 		ID:        uuid.New(),
@@ -262,6 +293,13 @@ func AddFeedHandler(s *State, cmd Command, user database.User) error {
 		return fmt.Errorf("error creating feed : %s", err)
 	}
 
+	if creds != nil {
+		err = api.SaveFeedCredentials(context.Background(), s.Db, s.Secrets, feed.ID, creds)
+		if err != nil {
+			return fmt.Errorf("couldn't save feed credentials: %w", err)
+		}
+	}
+
 	feedFollow, err := s.Db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
 		FeedID: feed.ID,
 		UserID: user.ID,
@@ -279,7 +317,39 @@ func AddFeedHandler(s *State, cmd Command, user database.User) error {
 	return nil
 }
 
+// chooseFeedCandidate returns the feed to add, asking the user when --pick is set
+func chooseFeedCandidate(candidates []api.FeedCandidate, pick bool) (string, error) {
+	if len(candidates) == 1 {
+		return candidates[0].URL, nil
+	}
+
+	fmt.Printf("Found %d feeds:\n", len(candidates))
+	for i, candidate := range candidates {
+		fmt.Printf("  %d. %s %s\n", i+1, candidate.URL, candidate.Title)
+	}
+
+	if !pick {
+		fmt.Printf("Using %s (pass --pick to choose another)\n", candidates[0].URL)
+		return candidates[0].URL, nil
+	}
+
+	fmt.Printf("Pick a feed [1-%d]: ", len(candidates))
+	var choice int
+	if _, err := fmt.Fscanln(os.Stdin, &choice); err != nil {
+		return "", fmt.Errorf("invalid choice: %w", err)
+	}
+	if choice < 1 || choice > len(candidates) {
+		return "", fmt.Errorf("choice must be between 1 and %d", len(candidates))
+	}
+
+	return candidates[choice-1].URL, nil
+}
+
 func GetAllFeeds(s *State, cmd Command) error {
+	flags, err := ParseFeedsFlags(cmd.Args)
+	if err != nil {
+		return err
+	}
 
 	feeds, err := s.Db.GetFeeds(context.Background())
 	if err != nil {
@@ -291,6 +361,11 @@ func GetAllFeeds(s *State, cmd Command) error {
 		return nil
 	}
 
+	if flags.Health {
+		printFeedHealth(feeds)
+		return nil
+	}
+
 	for _, feed := range feeds {
 		username, err := s.Db.GetUserFeed(context.Background(), feed.ID)
 		if err != nil {
@@ -316,6 +391,9 @@ func FollowHandler(s *State, cmd Command, user database.User) error {
 	if err != nil {
 		return fmt.Errorf("couldn't get feed by URL %w", err)
 	}
+	if err := api.CheckCanFollow(context.Background(), s.Db, feed, user.ID); err != nil {
+		return fmt.Errorf("couldn't follow feed: %w", err)
+	}
 
 	feedFollow, err := s.Db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
 		FeedID: feed.ID,
@@ -373,58 +451,136 @@ func UnfollowHandler(s *State, cmd Command, user database.User) error {
 	return nil
 }
 
-func ScrapeFeeds(s *State, feed database.Feed) error {
+// scrapeFeed fetches a feed, stores its new and edited posts and schedules the
+// next fetch, then records the attempt in the feed's fetch history
+func scrapeFeed(s *State, feed database.Feed) (ingestResult, error) {
+	attempt := fetchAttempt{FeedID: feed.ID, StartedAt: time.Now().UTC()}
+	stored, err := collectFeed(s, feed, &attempt)
+	attempt.Duration = time.Since(attempt.StartedAt)
+	attempt.NewPosts = stored.New
+	attempt.Err = err
 
+	metrics.ObserveFetch(attempt.outcome(), attempt.Duration)
+	if recordErr := recordFetchAttempt(context.Background(), s, attempt); recordErr != nil {
+		log.Printf("couldn't record fetch of feed %s: %s", feed.Name, recordErr)
+	}
+	return stored, err
+}
+
+// collectFeed does the work of scrapeFeed, noting what it sees in attempt
+func collectFeed(s *State, feed database.Feed, attempt *fetchAttempt) (ingestResult, error) {
 	err := s.Db.MarkFeedFetched(context.Background(), feed.ID)
 	if err != nil {
-		return fmt.Errorf("couldn't mark feed as fetched: %s", err)
+		return ingestResult{}, fmt.Errorf("couldn't mark feed as fetched: %s", err)
+	}
+
+	// A missing key or undecryptable credentials show up in the feed's health like any other failure
+	creds, err := api.LoadFeedCredentials(context.Background(), s.Db, s.Secrets, feed.ID)
+	if err != nil {
+		if recordErr := recordFetchFailure(context.Background(), s, feed, err); recordErr != nil {
+			log.Printf("couldn't record failure of feed %s: %s", feed.Name, recordErr)
+		}
+		return ingestResult{}, fmt.Errorf("couldn't load credentials for feed: %s", err)
 	}
 
-	feeds, err := fetchFeed(context.Background(), feed.Url)
+	result, err := fetchFeed(context.Background(), s.Fetcher, feed, creds)
+	attempt.observe(result, err)
 	if err != nil {
-		return fmt.Errorf("couldn't fetch feed with this URL: %s", err)
+		if recordErr := recordFetchFailure(context.Background(), s, feed, err); recordErr != nil {
+			log.Printf("couldn't record failure of feed %s: %s", feed.Name, recordErr)
+		}
+		return ingestResult{}, fmt.Errorf("couldn't fetch feed with this URL: %s", err)
 	}
 
-	for _, item := range feeds.Channel.Item {
-		PublishedAt := sql.NullTime{}
-		pubDate, err := time.Parse(time.RFC1123Z, item.PubDate)
-		if err == nil {
-			PublishedAt = sql.NullTime{
-				Time:  pubDate,
-				Valid: true,
-			}
+	// The feed moved permanently; follow it, merging with the new URL's feed if there is one.
+	// Feeds with credentials only follow moves on the same host, so secrets aren't sent elsewhere.
+	if result.MovedTo != "" && creds != nil && !sameFeedHost(feed.Url, result.MovedTo) {
+		log.Printf("feed %s moved to another host (%s), not following because it has credentials", feed.Name, result.MovedTo)
+	} else if result.MovedTo != "" {
+		feed, err = applyFeedMove(context.Background(), s, feed, result.MovedTo)
+		if err != nil {
+			return ingestResult{}, fmt.Errorf("couldn't move feed to %s: %s", result.MovedTo, err)
 		}
-		_, err = s.Db.CreatePost(context.Background(), database.CreatePostParams{
-			CreatedAt: time.Now().UTC(),
-			UpdatedAt: time.Now().UTC(),
-			Title:     item.Title,
-			Description: sql.NullString{
-				String: item.Description,
-				Valid:  true,
-			},
-			PublishedAt: PublishedAt,
-			Url:         item.Link,
-			FeedID:      feed.ID,
-		})
+		// Merged feeds are deleted, the history goes with the one that remains
+		attempt.FeedID = feed.ID
+	}
+
+	// Subscribe to the feed's WebSub hub, if it has one. Polling carries on as the fallback.
+	result.Pushed, err = ensureWebSub(context.Background(), s, feed, result)
+	if err != nil {
+		log.Printf("websub for feed %s: %s", feed.Name, err)
+	}
+
+	if result.NotModified {
+		if err := recordFetchSuccess(context.Background(), s, feed, result); err != nil {
+			return ingestResult{}, fmt.Errorf("couldn't update feed health: %s", err)
+		}
+		if err := saveCacheHeaders(context.Background(), s, feed, result); err != nil {
+			return ingestResult{}, err
+		}
+		next, err := scheduleNextFetch(context.Background(), s, feed, result)
 		if err != nil {
-			if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-				continue
-			}
-			fmt.Printf("Error creating posts :%s", err)
-			continue
+			return ingestResult{}, fmt.Errorf("couldn't schedule next fetch: %s", err)
+		}
+		log.Printf("Feed %s not modified since last fetch, next fetch at %s", feed.Name, next.Format(time.RFC3339))
+		return ingestResult{}, nil
+	}
+
+	items := result.Feed.Channel.Item
+	stored, err := storePosts(context.Background(), s, feed.ID, items, time.Now().UTC())
+	if err != nil {
+		// Backs the feed off like a failed fetch, rather than retrying it every tick
+		if recordErr := recordFetchFailure(context.Background(), s, feed, err); recordErr != nil {
+			log.Printf("couldn't record failure of feed %s: %s", feed.Name, recordErr)
 		}
+		return ingestResult{}, fmt.Errorf("couldn't store posts: %s", err)
 	}
+	metrics.ObservePosts(metrics.SourcePoll, stored.New, stored.Updated)
 
-	log.Printf("Feed %s collected, %v posts found", feed.Name, len(feeds.Channel.Item))
+	// The feed is only healthy once its posts are stored
+	if err := recordFetchSuccess(context.Background(), s, feed, result); err != nil {
+		return ingestResult{}, fmt.Errorf("couldn't update feed health: %s", err)
+	}
+
+	// Only once the posts are stored, or a failed store would be answered with a 304 next time
+	if err := saveCacheHeaders(context.Background(), s, feed, result); err != nil {
+		return ingestResult{}, err
+	}
+
+	// Schedule after storing the posts so the new ones count towards the posting frequency
+	next, err := scheduleNextFetch(context.Background(), s, feed, result)
+	if err != nil {
+		return ingestResult{}, fmt.Errorf("couldn't schedule next fetch: %s", err)
+	}
+
+	log.Printf("Feed %s collected: %d new, %d updated, %d unchanged of %d items, next fetch at %s",
+		feed.Name, stored.New, stored.Updated, stored.Unchanged, len(items), next.Format(time.RFC3339))
+	return stored, nil
+}
+
+// saveCacheHeaders remembers the validators for the next conditional GET
+func saveCacheHeaders(ctx context.Context, s *State, feed database.Feed, result *FetchResult) error {
+	err := s.Db.UpdateFeedCacheHeaders(ctx, database.UpdateFeedCacheHeadersParams{
+		ID:           feed.ID,
+		Etag:         sql.NullString{String: result.ETag, Valid: result.ETag != ""},
+		LastModified: sql.NullString{String: result.LastModified, Valid: result.LastModified != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't update feed cache headers: %s", err)
+	}
 	return nil
 }
 
 func displayPosts(posts []database.GetPostsForUserSortedRow, username string) {
 	fmt.Printf("Found %d posts for user %s:\n", len(posts), username)
 	for _, post := range posts {
-		fmt.Printf("%s from %s\n", post.PublishedAt.Time.Format("Mon Jan 2"), post.FeedName)
+		published := post.PublishedAt.Time.Format("Mon Jan 2")
+		if post.PublishedAtInferred {
+			published += " (approx.)"
+		}
+		fmt.Printf("%s from %s\n", published, post.FeedName)
 		fmt.Printf("---- %s-----", post.Title)
-		fmt.Printf("    %v\n", post.Description.String)
+		fmt.Printf("    %v\n", sanitize.Text(post.Description.String))
 		fmt.Printf("Link: %s\n", post.Url)
 		fmt.Println("=====================================")
 	}
@@ -580,8 +736,32 @@ func TUIHandler(s *State, cmd Command, user database.User) error {
 		return nil
 	}
 
+	// fetch podcast enclosures for the detail view
+	postIDs := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	enclosures, err := s.Db.GetEnclosuresForPosts(context.Background(), postIDs)
+	if err != nil {
+		return fmt.Errorf("couldn't get enclosures %w", err)
+	}
+	enclosuresByPost := make(map[uuid.UUID][]database.Enclosure)
+	for _, enclosure := range enclosures {
+		enclosuresByPost[enclosure.PostID] = append(enclosuresByPost[enclosure.PostID], enclosure)
+	}
+
+	// What changed in posts their publisher has edited
+	revisions, err := s.Db.GetLatestRevisionsForPosts(context.Background(), postIDs)
+	if err != nil {
+		return fmt.Errorf("couldn't get post revisions %w", err)
+	}
+	revisionsByPost := make(map[uuid.UUID]database.PostRevision)
+	for _, revision := range revisions {
+		revisionsByPost[revision.PostID] = revision
+	}
+
 	// Create and run the TUI
-	model := NewTUI(posts)
+	model := NewTUI(posts, enclosuresByPost, revisionsByPost)
 	p := tea.NewProgram(model, tea.WithAltScreen())
 
 	if _, err = p.Run(); err != nil {
@@ -1,15 +1,63 @@
 package config
 
-import "github.com/eniolaomotee/BlogGator-Go/internal/database"
+import (
+	"database/sql"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+)
 
 type Config struct {
 	DbURL    string `json:"db_url"`
 	UserName string `json:"current_user_name"`
+
+	// Bounds for the adaptive fetch schedule, as durations like "15m" or "12h"
+	MinFetchInterval string `json:"min_fetch_interval,omitempty"`
+	MaxFetchInterval string `json:"max_fetch_interval,omitempty"`
+
+	// Consecutive failed fetches before a feed is disabled
+	DisableAfterFailures int `json:"disable_after_failures,omitempty"`
+
+	// How feeds and pages are downloaded, see (*Config).FetchPolicy in fetch_policy.go for the defaults
+	UserAgent          string `json:"user_agent,omitempty"`
+	Contact            string `json:"contact,omitempty"` // email or URL added to the default User-Agent
+	ConnectTimeout     string `json:"connect_timeout,omitempty"`
+	FetchTimeout       string `json:"fetch_timeout,omitempty"`
+	MaxResponseBytes   int64  `json:"max_response_bytes,omitempty"`
+	PerHostConcurrency int    `json:"per_host_concurrency,omitempty"`
+	PerHostDelay       string `json:"per_host_delay,omitempty"`
+	RespectRobotsTxt   bool   `json:"respect_robots_txt,omitempty"`
+
+	// Internal hosts, domains ("*.corp.example") and CIDR ranges that may be
+	// fetched, and extra ports allowed besides 80 and 443
+	FetchAllowlist []string `json:"fetch_allowlist,omitempty"`
+	FetchPorts     []int    `json:"fetch_ports,omitempty"`
+
+	// Public base URL of `gator serve`, e.g. "https://gator.example.com". When
+	// set, feeds with a WebSub hub are subscribed to and pushed to this server.
+	WebSubCallbackURL string `json:"websub_callback_url,omitempty"`
+
+	// Addresses like ":9101" where `gator agg` and the service manager serve
+	// Prometheus metrics; `gator serve` always has them at /metrics
+	MetricsAddr        string `json:"metrics_addr,omitempty"`
+	ServiceMetricsAddr string `json:"service_metrics_addr,omitempty"`
+
+	// Days of fetch history kept, 30 when unset
+	FetchHistoryDays int `json:"fetch_history_days,omitempty"`
 }
 
 type State struct {
 	Conf *Config
 	Db   *database.Queries
+	Conn *sql.DB // for work that needs a transaction
+
+	// Fetcher is shared by everything that downloads, so per-host limits hold across workers
+	Fetcher *fetch.Fetcher
+
+	// Secrets encrypts feed credentials, nil when GATOR_ENCRYPTION_KEY isn't set
+	Secrets *api.SecretBox
 }
 
 type Command struct {
@@ -21,12 +69,40 @@ type Commands struct {
 	CliCommands map[string]func(*State, Command) error
 }
 
+// FetchResult is the outcome of a single conditional GET of a feed
+type FetchResult struct {
+	Feed         *RSSFeed // nil when NotModified
+	NotModified  bool
+	Status       int   // HTTP status of the final response
+	Bytes        int64 // size of the body read
+	ETag         string
+	LastModified string
+	MaxAge       time.Duration // Cache-Control max-age, zero when absent
+	MovedTo      string        // new URL after permanent redirects, empty when the feed didn't move
+
+	// WebSub hub and topic from the Link header or the feed, empty when it has no hub
+	Hub   string
+	Topic string
+	// Pushed is set when the hub pushes updates, so polling is only a fallback
+	Pushed bool
+}
+
 type RSSFeed struct {
 	Channel struct {
+		// atom:link elements (hub, self). Declared before Link so they don't land in it.
+		AtomLinks []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+
 		Title       string    `xml:"title"`
 		Link        string    `xml:"link"`
 		Description string    `xml:"description"`
 		Item        []RSSItem `xml:"item"`
+
+		// Publisher hints on how often to poll
+		TTL             string   `xml:"ttl"`
+		SkipHours       []string `xml:"skipHours>hour"`
+		SkipDays        []string `xml:"skipDays>day"`
+		UpdatePeriod    string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
 	} `xml:"channel"`
 }
 
@@ -35,6 +111,41 @@ type RSSItem struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	DCDate      string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Updated     string `xml:"http://www.w3.org/2005/Atom updated"`
+	GUID        string `xml:"guid"`
+	Author      string `xml:"author"`
+	DCCreator   string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+
+	// Podcast episodes
+	Enclosures []RSSEnclosure `xml:"enclosure"`
+	Media      []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	Duration   string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+}
+
+// RSSEnclosure is an <enclosure>, also used for Atom and JSON Feed attachments.
+// Length stays a string because feeds often leave it empty or put junk in it.
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// MediaContent is a Media RSS <media:content> element
+type MediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+type AggFlags struct {
+	Interval    time.Duration // between fetches, when not --once
+	Once        bool          // fetch every due feed once and exit
+	JSON        bool          // print the --once summary as JSON
+	MaxFailures int           // failed feeds tolerated before --once exits non-zero
+	MetricsAddr string        // where to serve /metrics, overrides metrics_addr
 }
 
 type BrowseFlags struct {
@@ -45,8 +156,154 @@ type BrowseFlags struct {
 	Page       int
 }
 
+type AddFeedFlags struct {
+	Name string
+	URL  string
+	Pick bool
+
+	// Credentials for private feeds
+	User     string   // user for basic auth, the password comes from GATOR_FEED_PASSWORD or a prompt
+	Token    string   // bearer token
+	Headers  []string // "Name: value"
+	CertFile string   // client certificate, PEM
+	KeyFile  string   // client key, PEM
+	CAFile   string   // extra CA bundle, PEM
+}
+
+type RefreshFlags struct {
+	URL string // feed to refresh, when not --all
+	All bool   // every feed the user added or follows
+}
+
+type PodcastFlags struct {
+	Action string // list or download
+	Feed   string
+	Dir    string
+	Keep   int // episodes downloaded per feed
+	Limit  int // episodes listed per feed
+}
+
+type FeedFlags struct {
+	Action string // history, events or enable
+	URL    string
+	Limit  int // fetch attempts to show
+}
+
+type FeedsFlags struct {
+	Health bool
+}
+
+type ReadFlags struct {
+	URL     string
+	Feed    string // feed URL, for links more than one feed posted
+	Extract bool
+}
+
 type SearchFlags struct {
 	Field string
 	Query string
 	Limit int
 }
+
+// AtomFeed is an Atom 1.0 document, mapped onto RSSFeed after parsing
+type AtomFeed struct {
+	Title           string      `xml:"title"`
+	Subtitle        string      `xml:"subtitle"`
+	Links           []AtomLink  `xml:"link"`
+	Entries         []AtomEntry `xml:"entry"`
+	UpdatePeriod    string      `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string      `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+}
+
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type AtomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Links     []AtomLink   `xml:"link"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+	Authors   []AtomPerson `xml:"author"`
+	Summary   AtomText     `xml:"summary"`
+	Content   AtomText     `xml:"content"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+// AtomText holds a text construct; xhtml content is kept as raw markup
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// RDFFeed is an RSS 1.0 document, where items are siblings of the channel
+type RDFFeed struct {
+	Channel struct {
+		Title           string `xml:"title"`
+		Link            string `xml:"link"`
+		Description     string `xml:"description"`
+		UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	} `xml:"channel"`
+	Items []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+}
+
+// JSONFeed is a JSON Feed 1.1 document, mapped onto RSSFeed after parsing
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	FeedURL     string         `json:"feed_url"`
+	Hubs        []JSONFeedHub  `json:"hubs"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedHub struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type JSONFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Authors       []JSONFeedAuthor     `json:"authors"`
+	Author        *JSONFeedAuthor      `json:"author"` // JSON Feed 1.0
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+type JSONFeedAttachment struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+	"golang.org/x/term"
+)
+
+// feedPasswordEnv holds the basic auth password for addfeed --user, so it
+// doesn't show up in the process list or shell history
+const feedPasswordEnv = "GATOR_FEED_PASSWORD"
+
+// feedClient returns a client that presents the feed's client certificate and
+// trusts its CA bundle. Extra headers are dropped when a redirect leaves the
+// feed's host; net/http already does that for Authorization.
+func feedClient(fetcher *fetch.Fetcher, creds *api.FeedCredentials, checkRedirect func(req *http.Request, via []*http.Request) error) (*http.Client, error) {
+	tlsConfig, err := creds.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if creds != nil && len(creds.Headers) > 0 {
+		next := checkRedirect
+		checkRedirect = func(req *http.Request, via []*http.Request) error {
+			if !sameHost(req.URL, via[0].URL) {
+				for name := range creds.Headers {
+					req.Header.Del(name)
+				}
+			}
+			if next != nil {
+				return next(req, via)
+			}
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return nil
+		}
+	}
+
+	return fetcher.ClientWithTLS(tlsConfig, checkRedirect), nil
+}
+
+func sameHost(a, b *url.URL) bool {
+	return strings.EqualFold(a.Host, b.Host)
+}
+
+// sameFeedHost is sameHost for raw URLs, false when either doesn't parse
+func sameFeedHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return sameHost(ua, ub)
+}
+
+// addFeedCredentials builds credentials from the addfeed flags, reading the
+// certificate files. It returns nil when no credential flags were given.
+func addFeedCredentials(flags *AddFeedFlags) (*api.FeedCredentials, error) {
+	creds := &api.FeedCredentials{Username: flags.User, BearerToken: flags.Token}
+	if flags.User != "" {
+		password, err := feedPassword(flags.User)
+		if err != nil {
+			return nil, err
+		}
+		creds.Password = password
+	}
+
+	for _, header := range flags.Headers {
+		name, value, _ := strings.Cut(header, ":")
+		if creds.Headers == nil {
+			creds.Headers = make(map[string]string)
+		}
+		creds.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	files := []struct {
+		path   string
+		target *string
+	}{
+		{flags.CertFile, &creds.ClientCert},
+		{flags.KeyFile, &creds.ClientKey},
+		{flags.CAFile, &creds.CABundle},
+	}
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read %s: %w", file.path, err)
+		}
+		*file.target = string(data)
+	}
+
+	if creds.IsZero() {
+		return nil, nil
+	}
+	if err := creds.Validate(); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// feedPassword reads the basic auth password from GATOR_FEED_PASSWORD, asking
+// for it when that isn't set and stdin is a terminal
+func feedPassword(user string) (string, error) {
+	if password, ok := os.LookupEnv(feedPasswordEnv); ok {
+		return password, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("set %s to the password for %s", feedPasswordEnv, user)
+	}
+
+	fmt.Printf("Password for %s: ", user)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("couldn't read password: %w", err)
+	}
+	return string(password), nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+)
+
+func TestFetchFeedCredentials(t *testing.T) {
+	const feed = `<rss version="2.0"><channel><title>Private</title></channel></rss>`
+
+	// Another host, as far as the client can tell
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("Private-Token")
+		w.Write([]byte(feed))
+	}))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/elsewhere" {
+			http.Redirect(w, r, otherURL+"/feed", http.StatusFound)
+			return
+		}
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "alice" || password != "secret" || r.Header.Get("Private-Token") != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	fetcher := fetch.New(fetch.Policy{})
+	creds := &api.FeedCredentials{
+		Username: "alice",
+		Password: "secret",
+		Headers:  map[string]string{"Private-Token": "abc"},
+	}
+
+	if _, err := fetchFeed(context.Background(), fetcher, database.Feed{Url: server.URL + "/feed"}, nil); err == nil {
+		t.Fatalf("Expected the feed to need credentials")
+	}
+
+	result, err := fetchFeed(context.Background(), fetcher, database.Feed{Url: server.URL + "/feed"}, creds)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Feed.Channel.Title != "Private" {
+		t.Fatalf("Expected title %q, got %q", "Private", result.Feed.Channel.Title)
+	}
+
+	if _, err := fetchFeed(context.Background(), fetcher, database.Feed{Url: server.URL + "/elsewhere"}, creds); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if leaked != "" {
+		t.Fatalf("Expected the header to be dropped on a redirect to another host, got %q", leaked)
+	}
+}
+
+func TestParseAddFeedFlags(t *testing.T) {
+	t.Setenv(feedPasswordEnv, "secret")
+
+	flags, err := ParseAddFeedFlags([]string{
+		"Jira", "https://jira.example.com/activity",
+		"--user", "alice",
+		"--header=X-Api-Key: abc",
+		"--header", "X-Other: def",
+		"--pick",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if flags.Name != "Jira" || flags.URL != "https://jira.example.com/activity" || !flags.Pick {
+		t.Fatalf("Unexpected positional arguments: %+v", flags)
+	}
+
+	creds, err := addFeedCredentials(flags)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if creds.Username != "alice" || creds.Password != "secret" {
+		t.Fatalf("Expected alice/secret, got %s/%s", creds.Username, creds.Password)
+	}
+	if creds.Headers["X-Api-Key"] != "abc" || creds.Headers["X-Other"] != "def" {
+		t.Fatalf("Unexpected headers: %v", creds.Headers)
+	}
+
+	if _, err := ParseAddFeedFlags([]string{"Jira", "https://jira.example.com", "--header", "no colon"}); err == nil {
+		t.Fatalf("Expected an error for a header without a colon")
+	}
+	if _, err := ParseAddFeedFlags([]string{"Jira", "https://jira.example.com", "--user", "alice:secret"}); err == nil {
+		t.Fatalf("Expected an error for a password in the arguments")
+	}
+
+	flags, _ = ParseAddFeedFlags([]string{"Blog", "https://example.com"})
+	if creds, err := addFeedCredentials(flags); err != nil || creds != nil {
+		t.Fatalf("Expected no credentials, got %v, %v", creds, err)
+	}
+}
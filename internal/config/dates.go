@@ -0,0 +1,115 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// pubDateLayouts are the date formats seen in the wild, tried in order.
+// Zone abbreviations are rewritten to numeric offsets before parsing.
+var pubDateLayouts = []string{
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 06 15:04:05 -0700",
+	"Mon, 2 Jan 06 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"Mon, 2 January 2006 15:04:05 -0700",
+	"Monday, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05",
+	"Mon Jan 2 15:04:05 -0700 2006",
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// zoneOffsets maps the timezone abbreviations feeds use to numeric offsets.
+// time.Parse silently treats unknown abbreviations as UTC, so we do it ourselves.
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"BST":  "+0100",
+	"IST":  "+0530",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+// parsePubDate parses a single date string in any of the supported formats
+func parsePubDate(value string) (time.Time, bool) {
+	value = normalizeDate(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range pubDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalizeDate collapses whitespace and replaces a trailing zone abbreviation with its offset
+func normalizeDate(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	last := strings.ToUpper(fields[len(fields)-1])
+	if offset, ok := zoneOffsets[last]; ok && len(fields) > 1 {
+		fields[len(fields)-1] = offset
+	}
+
+	// Some feeds put a comma after the day name without a space
+	joined := strings.Join(fields, " ")
+	if i := strings.Index(joined, ","); i > 0 && i+1 < len(joined) && joined[i+1] != ' ' {
+		joined = joined[:i+1] + " " + joined[i+1:]
+	}
+	return joined
+}
+
+// itemPublishedAt returns when an item was published, trying pubDate, dc:date
+// and the updated stamp in turn. When none of them parse it falls back to the
+// fetch time and reports the date as inferred.
+func itemPublishedAt(item RSSItem, fetchedAt time.Time) (publishedAt time.Time, inferred bool) {
+	for _, candidate := range []string{item.PubDate, item.DCDate, item.Updated} {
+		if t, ok := parsePubDate(candidate); ok {
+			return t, false
+		}
+	}
+	return fetchedAt.UTC(), true
+}
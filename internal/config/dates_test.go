@@ -0,0 +1,63 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePubDate(t *testing.T) {
+	want := time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Time
+		wantOK bool
+	}{
+		{name: "rfc1123z", value: "Tue, 05 Mar 2024 14:30:00 +0000", want: want, wantOK: true},
+		{name: "rfc1123 with GMT", value: "Tue, 05 Mar 2024 14:30:00 GMT", want: want, wantOK: true},
+		{name: "zone abbreviation", value: "Tue, 05 Mar 2024 09:30:00 EST", want: want, wantOK: true},
+		{name: "single digit day", value: "Tue, 5 Mar 2024 14:30:00 +0000", want: want, wantOK: true},
+		{name: "rfc822 two digit year", value: "05 Mar 24 14:30 UT", want: want, wantOK: true},
+		{name: "no seconds", value: "Tue, 05 Mar 2024 15:30 +0100", want: want, wantOK: true},
+		{name: "iso 8601", value: "2024-03-05T14:30:00Z", want: want, wantOK: true},
+		{name: "iso 8601 with offset", value: "2024-03-05T16:30:00+02:00", want: want, wantOK: true},
+		{name: "iso 8601 fractional seconds", value: "2024-03-05T14:30:00.000Z", want: want, wantOK: true},
+		{name: "date only", value: "2024-03-05", want: time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC), wantOK: true},
+		{name: "extra whitespace", value: "  Tue,  05 Mar 2024\n14:30:00 +0000 ", want: want, wantOK: true},
+		{name: "empty", value: "", wantOK: false},
+		{name: "garbage", value: "last tuesday", wantOK: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := parsePubDate(test.value)
+			if ok != test.wantOK {
+				t.Fatalf("Expected ok: %v, got: %v", test.wantOK, ok)
+			}
+			if ok && !got.Equal(test.want) {
+				t.Fatalf("Expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestItemPublishedAtFallback(t *testing.T) {
+	fetchedAt := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	got, inferred := itemPublishedAt(RSSItem{PubDate: "not a date", DCDate: "2024-05-01T08:00:00Z"}, fetchedAt)
+	if inferred {
+		t.Fatalf("Expected dc:date to be used, got inferred date")
+	}
+	if !got.Equal(time.Date(2024, time.May, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected dc:date, got %s", got)
+	}
+
+	got, inferred = itemPublishedAt(RSSItem{}, fetchedAt)
+	if !inferred {
+		t.Fatalf("Expected date to be inferred")
+	}
+	if !got.Equal(fetchedAt) {
+		t.Fatalf("Expected fetch time %s, got %s", fetchedAt, got)
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+	"golang.org/x/net/html"
+)
+
+// feedLinkTypes are the <link rel="alternate"> types that point at a feed
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/rdf+xml":   true,
+}
+
+// commonFeedPaths are probed when a page doesn't advertise any feed
+var commonFeedPaths = []string{"/feed", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml", "/feed.json"}
+
+// discoverFeeds resolves a URL into feed URLs. A URL that already serves a
+// feed is returned as the only candidate; an HTML page is searched for
+// advertised feeds and, failing that, common feed paths on the same site.
+func discoverFeeds(ctx context.Context, fetcher *fetch.Fetcher, pageURL string, creds *api.FeedCredentials) ([]api.FeedCandidate, error) {
+	data, contentType, finalURL, err := getDocument(ctx, fetcher, pageURL, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	if feed, err := parseFeed(data, contentType); err == nil {
+		return []api.FeedCandidate{{URL: pageURL, Title: strings.TrimSpace(feed.Channel.Title)}}, nil
+	}
+
+	if !isHTML(data, contentType) {
+		return nil, fmt.Errorf("%s is neither a feed nor an HTML page", pageURL)
+	}
+
+	candidates := feedLinksFromHTML(data, finalURL)
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	// Nothing advertised, probe the usual suspects. Credentials stay with the
+	// host they were given for.
+	probeCreds := creds
+	if original, err := url.Parse(pageURL); err != nil || !sameHost(original, finalURL) {
+		probeCreds = nil
+	}
+	for _, path := range commonFeedPaths {
+		probe := finalURL.ResolveReference(&url.URL{Path: path})
+		data, contentType, _, err := getDocument(ctx, fetcher, probe.String(), probeCreds)
+		if err != nil {
+			continue
+		}
+		if feed, err := parseFeed(data, contentType); err == nil {
+			candidates = append(candidates, api.FeedCandidate{
+				URL:   probe.String(),
+				Title: strings.TrimSpace(feed.Channel.Title),
+			})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no feeds found at %s", pageURL)
+	}
+	return candidates, nil
+}
+
+// getDocument fetches a URL, with credentials when creds isn't nil, and returns
+// its body, content type and the URL after redirects
+func getDocument(ctx context.Context, fetcher *fetch.Fetcher, rawURL string, creds *api.FeedCredentials) ([]byte, string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error creating request %w", err)
+	}
+	creds.Apply(req)
+
+	client, err := feedClient(fetcher, creds, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error sending request %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", nil, fmt.Errorf("unexpected status: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	data, err := fetcher.ReadAll(resp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error reading data from response %w", err)
+	}
+
+	return data, resp.Header.Get("Content-Type"), resp.Request.URL, nil
+}
+
+// isHTML reports whether the document is an HTML page
+func isHTML(data []byte, contentType string) bool {
+	if strings.Contains(strings.ToLower(contentType), "html") {
+		return true
+	}
+	return strings.Contains(http.DetectContentType(data), "text/html")
+}
+
+// feedLinksFromHTML collects the feeds advertised in the page's <link> tags
+func feedLinksFromHTML(data []byte, base *url.URL) []api.FeedCandidate {
+	var candidates []api.FeedCandidate
+	seen := map[string]bool{}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return candidates
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		if token.Data == "body" {
+			// Feed links live in <head>
+			return candidates
+		}
+		if token.Data != "link" {
+			continue
+		}
+
+		var rel, linkType, href, title string
+		for _, attr := range token.Attr {
+			switch strings.ToLower(attr.Key) {
+			case "rel":
+				rel = strings.ToLower(attr.Val)
+			case "type":
+				linkType = strings.ToLower(strings.TrimSpace(attr.Val))
+			case "href":
+				href = strings.TrimSpace(attr.Val)
+			case "title":
+				title = attr.Val
+			}
+		}
+
+		if !hasRel(rel, "alternate") || !feedLinkTypes[linkType] || href == "" {
+			continue
+		}
+
+		ref, err := url.Parse(href)
+		if err != nil {
+			continue
+		}
+		resolved := base.ResolveReference(ref).String()
+		if seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+
+		candidates = append(candidates, api.FeedCandidate{
+			URL:   resolved,
+			Title: title,
+			Type:  linkType,
+		})
+	}
+}
+
+// hasRel reports whether a space separated rel attribute contains value
+func hasRel(rel, value string) bool {
+	for _, r := range strings.Fields(rel) {
+		if r == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestFeedLinksFromHTML(t *testing.T) {
+	base, _ := url.Parse("https://blog.example.com/posts/")
+	page := `<!DOCTYPE html>
+<html><head>
+<title>Blog</title>
+<link rel="stylesheet" href="/style.css">
+<link rel="alternate" type="application/rss+xml" title="RSS" href="/rss.xml">
+<link rel="alternate" type="application/atom+xml" title="Atom" href="https://blog.example.com/atom.xml">
+<link rel="Alternate" type="application/feed+json" href="feed.json">
+<link rel="alternate" type="application/rss+xml" href="/rss.xml">
+<link rel="alternate" hreflang="fr" href="/fr/">
+</head><body>
+<link rel="alternate" type="application/rss+xml" href="/comments.xml">
+</body></html>`
+
+	got := feedLinksFromHTML([]byte(page), base)
+
+	want := []string{
+		"https://blog.example.com/rss.xml",
+		"https://blog.example.com/atom.xml",
+		"https://blog.example.com/posts/feed.json",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d candidates, got %d: %+v", len(want), len(got), got)
+	}
+	for i, url := range want {
+		if got[i].URL != url {
+			t.Fatalf("Candidate %d: expected %s, got %s", i, url, got[i].URL)
+		}
+	}
+	if got[0].Title != "RSS" || got[0].Type != "application/rss+xml" {
+		t.Fatalf("Expected title and type to be kept, got %+v", got[0])
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// itemEnclosure is an episode attachment normalised from <enclosure> and <media:content>
+type itemEnclosure struct {
+	URL             string
+	MimeType        string
+	LengthBytes     int64 // 0 when unknown
+	DurationSeconds int32 // 0 when unknown
+}
+
+// episodeEnclosures merges the item's enclosures and media:content into one list, keyed by URL
+func episodeEnclosures(item RSSItem) []itemEnclosure {
+	itemDuration, _ := parseEpisodeDuration(item.Duration)
+
+	var enclosures []itemEnclosure
+	seen := map[string]bool{}
+
+	add := func(url, mimeType, length, duration string) {
+		url = strings.TrimSpace(url)
+		if url == "" || seen[url] {
+			return
+		}
+		seen[url] = true
+
+		enclosure := itemEnclosure{
+			URL:             url,
+			MimeType:        strings.TrimSpace(mimeType),
+			DurationSeconds: itemDuration,
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(length), 10, 64); err == nil && n > 0 {
+			enclosure.LengthBytes = n
+		}
+		if d, ok := parseEpisodeDuration(duration); ok {
+			enclosure.DurationSeconds = d
+		}
+		enclosures = append(enclosures, enclosure)
+	}
+
+	for _, e := range item.Enclosures {
+		add(e.URL, e.Type, e.Length, "")
+	}
+	for _, m := range item.Media {
+		// media:content is also used for thumbnails, only keep audio and video
+		if m.Type != "" && !strings.HasPrefix(m.Type, "audio/") && !strings.HasPrefix(m.Type, "video/") {
+			continue
+		}
+		add(m.URL, m.Type, m.FileSize, m.Duration)
+	}
+
+	return enclosures
+}
+
+// parseEpisodeDuration parses itunes:duration, which is either seconds or [HH:]MM:SS
+func parseEpisodeDuration(value string) (int32, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	var total float64
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		total = total*60 + n
+	}
+
+	if total <= 0 {
+		return 0, false
+	}
+	return int32(total), true
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEpisodeEnclosures(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:media="http://search.yahoo.com/mrss/">
+<channel><title>Show</title>
+<item><title>Ep 1</title><link>https://example.com/1</link>
+<enclosure url="https://cdn.example.com/ep1.mp3" type="audio/mpeg" length="1234"/>
+<media:content url="https://cdn.example.com/ep1.mp3" type="audio/mpeg"/>
+<media:content url="https://cdn.example.com/ep1.jpg" type="image/jpeg"/>
+<media:content url="https://cdn.example.com/ep1.mp4" type="video/mp4" fileSize="99" duration="61"/>
+<itunes:duration>1:02:03</itunes:duration>
+</item>
+</channel></rss>`
+
+	feed, err := parseFeed([]byte(data), "")
+	if err != nil {
+		t.Fatalf("Error parsing feed: %s", err)
+	}
+
+	got := episodeEnclosures(feed.Channel.Item[0])
+	want := []itemEnclosure{
+		{URL: "https://cdn.example.com/ep1.mp3", MimeType: "audio/mpeg", LengthBytes: 1234, DurationSeconds: 3723},
+		{URL: "https://cdn.example.com/ep1.mp4", MimeType: "video/mp4", LengthBytes: 99, DurationSeconds: 61},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d enclosures, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Enclosure %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestParseEpisodeDuration(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   int32
+		wantOK bool
+	}{
+		{value: "3600", want: 3600, wantOK: true},
+		{value: "45:30", want: 2730, wantOK: true},
+		{value: "01:00:05", want: 3605, wantOK: true},
+		{value: "", wantOK: false},
+		{value: "about an hour", wantOK: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, ok := parseEpisodeDuration(test.value)
+			if ok != test.wantOK || got != test.want {
+				t.Fatalf("Expected (%d, %v), got (%d, %v)", test.want, test.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestPruneEpisodes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"old.mp3", "new.mp3", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, episodeManifest), []byte("old.mp3\nnew.mp3\n../outside\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pruneEpisodes(dir, map[string]bool{"new.mp3": true}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "old.mp3")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the old episode to be removed")
+	}
+	for _, name := range []string{"new.mp3", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Expected %s to be left alone, got %s", name, err)
+		}
+	}
+	manifest, _ := os.ReadFile(filepath.Join(dir, episodeManifest))
+	if string(manifest) != "new.mp3\n" {
+		t.Fatalf("Expected the manifest to list the kept episode, got %q", manifest)
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	if got := safeFileName(`a/b: "c"?`); got != "a-b- -c--" {
+		t.Fatalf("Expected unsafe characters replaced, got %q", got)
+	}
+
+	long := safeFileName("2024-01-01 " + strings.Repeat("é", 100))
+	if len(long) > 120 || !utf8.ValidString(long) {
+		t.Fatalf("Expected at most 120 bytes of valid UTF-8, got %d bytes %q", len(long), long)
+	}
+}
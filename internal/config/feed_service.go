@@ -0,0 +1,26 @@
+package config
+
+import (
+	"context"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/google/uuid"
+)
+
+// feedService implements api.FeedService on top of the CLI's feed fetching
+type feedService struct {
+	s *State
+}
+
+func (f *feedService) DiscoverFeeds(ctx context.Context, pageURL string, creds *api.FeedCredentials) ([]api.FeedCandidate, error) {
+	return discoverFeeds(ctx, f.s.Fetcher, pageURL, creds)
+}
+
+func (f *feedService) IngestPush(ctx context.Context, feedID uuid.UUID, body []byte, contentType string) error {
+	return ingestPush(ctx, f.s, feedID, body, contentType)
+}
+
+func (f *feedService) RefreshFeeds(ctx context.Context, userID uuid.UUID, feeds []database.Feed) ([]api.RefreshResult, error) {
+	return refreshFeeds(ctx, f.s, userID, feeds)
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+	"github.com/eniolaomotee/BlogGator-Go/internal/netguard"
+)
+
+// FetchPolicy builds the download policy from the config, using the defaults
+// from fetch.DefaultPolicy for anything unset or invalid. Private and internal
+// addresses are blocked unless they are in fetch_allowlist.
+func (cfg *Config) FetchPolicy() (fetch.Policy, error) {
+	policy := fetch.DefaultPolicy()
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	ports := cfg.FetchPorts
+	if len(ports) > 0 {
+		ports = append(append([]int(nil), netguard.DefaultPorts...), ports...)
+	}
+	guard, err := netguard.New(netguard.Options{Allow: cfg.FetchAllowlist, Ports: ports})
+	if err != nil {
+		return policy, fmt.Errorf("invalid fetch_allowlist or fetch_ports: %w", err)
+	}
+	policy.Guard = guard
+
+	policy.UserAgent = fetch.UserAgent(cfg.Contact)
+	if cfg.UserAgent != "" {
+		policy.UserAgent = cfg.UserAgent
+	}
+
+	policy.ConnectTimeout = configDuration("connect_timeout", cfg.ConnectTimeout, policy.ConnectTimeout)
+	policy.RequestTimeout = configDuration("fetch_timeout", cfg.FetchTimeout, policy.RequestTimeout)
+	policy.PerHostDelay = configDuration("per_host_delay", cfg.PerHostDelay, policy.PerHostDelay)
+
+	if cfg.MaxResponseBytes > 0 {
+		policy.MaxBodyBytes = cfg.MaxResponseBytes
+	}
+	if cfg.PerHostConcurrency > 0 {
+		policy.PerHostConcurrency = cfg.PerHostConcurrency
+	}
+	policy.RespectRobots = cfg.RespectRobotsTxt
+
+	return policy, nil
+}
+
+// configDuration parses a duration setting, keeping fallback when it's unset or invalid.
+// "0s" is allowed so that per_host_delay can be turned off.
+func configDuration(name, value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
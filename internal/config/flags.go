@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // parseFlagValue is a helper to parse flag values in both formats: --flag=value or --flag value
@@ -239,3 +242,340 @@ func ParseSearchFlags(args []string) (*SearchFlags, error) {
 	return flags, nil
 
 }
+
+// Parse addfeed flags: addfeed <name> <url> [--pick] [--user user] [--token t]
+// [--header "Name: value"]... [--cert file --key file] [--ca file]
+func ParseAddFeedFlags(args []string) (*AddFeedFlags, error) {
+	flags := &AddFeedFlags{}
+
+	// Flags that take a value, and where to put it
+	valueFlags := map[string]*string{
+		"--user":  &flags.User,
+		"--token": &flags.Token,
+		"--cert":  &flags.CertFile,
+		"--key":   &flags.KeyFile,
+		"--ca":    &flags.CAFile,
+	}
+
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--pick" {
+			flags.Pick = true
+			continue
+		}
+
+		name, _, _ := strings.Cut(arg, "=")
+		if target, ok := valueFlags[name]; ok {
+			val, newIndex, err := parseFlagValue(args, i, name, "")
+			if err != nil {
+				return nil, err
+			}
+			*target = val
+			i = newIndex
+			continue
+		}
+		if name == "--header" {
+			val, newIndex, err := parseFlagValue(args, i, name, "")
+			if err != nil {
+				return nil, err
+			}
+			if !strings.Contains(val, ":") {
+				return nil, fmt.Errorf("invalid header %q (expected \"Name: value\")", val)
+			}
+			flags.Headers = append(flags.Headers, val)
+			i = newIndex
+			continue
+		}
+
+		if strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("unknown flag: %s", arg)
+		}
+		positional = append(positional, arg)
+	}
+
+	// Arguments are visible to other users of the machine
+	if strings.Contains(flags.User, ":") {
+		return nil, fmt.Errorf("--user takes only the user name, the password is read from %s or asked for", feedPasswordEnv)
+	}
+
+	if len(positional) != 2 {
+		return nil, fmt.Errorf("usage: addfeed <name> <url> [--pick] [--user user] [--token token] [--header \"Name: value\"] [--cert file --key file] [--ca file]")
+	}
+	flags.Name = positional[0]
+	flags.URL = positional[1]
+
+	return flags, nil
+}
+
+// Parse agg flags: agg <time_between_reqs> | agg --once [--json] [--max-failures n]
+func ParseAggFlags(args []string) (*AggFlags, error) {
+	const usage = "usage : agg <time_between_reqs> [--metrics-addr :9101], e.g 'agg 1m', or agg --once [--json] [--max-failures n]"
+	flags := &AggFlags{}
+
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--once":
+			flags.Once = true
+		case arg == "--json":
+			flags.JSON = true
+		case arg == "--max-failures" || strings.HasPrefix(arg, "--max-failures="):
+			val, newIndex, err := parseIntFlag(args, i, "--max-failures", "")
+			if err != nil {
+				return nil, err
+			}
+			if val < 0 {
+				return nil, fmt.Errorf("--max-failures can't be negative")
+			}
+			flags.MaxFailures = val
+			i = newIndex
+		case arg == "--metrics-addr" || strings.HasPrefix(arg, "--metrics-addr="):
+			val, newIndex, err := parseFlagValue(args, i, "--metrics-addr", "")
+			if err != nil {
+				return nil, err
+			}
+			flags.MetricsAddr = val
+			i = newIndex
+		case strings.HasPrefix(arg, "-"):
+			return nil, fmt.Errorf("unknown flag: %s (%s)", arg, usage)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if flags.Once {
+		if len(positional) > 0 {
+			return nil, fmt.Errorf("--once takes no interval (%s)", usage)
+		}
+		// Nothing would be around to scrape a single run
+		if flags.MetricsAddr != "" {
+			return nil, fmt.Errorf("--metrics-addr doesn't apply with --once")
+		}
+		return flags, nil
+	}
+
+	if flags.JSON || flags.MaxFailures > 0 {
+		return nil, fmt.Errorf("--json and --max-failures only apply with --once")
+	}
+	if len(positional) != 1 {
+		return nil, fmt.Errorf(usage)
+	}
+	interval, err := time.ParseDuration(positional[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration :%s", err)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid duration :%s must be positive", positional[0])
+	}
+	flags.Interval = interval
+
+	return flags, nil
+}
+
+// Parse feeds flags: feeds [--health]
+func ParseFeedsFlags(args []string) (*FeedsFlags, error) {
+	flags := &FeedsFlags{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--health" {
+			flags.Health = true
+			continue
+		}
+		return nil, fmt.Errorf("unknown flag: %s (usage: feeds [--health])", arg)
+	}
+
+	return flags, nil
+}
+
+// Parse read flags: read <post url> [--feed feed_url] [--extract]
+func ParseReadFlags(args []string) (*ReadFlags, error) {
+	flags := &ReadFlags{}
+
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--extract" {
+			flags.Extract = true
+			continue
+		}
+		if arg == "--feed" || strings.HasPrefix(arg, "--feed=") {
+			val, newIndex, err := parseFlagValue(args, i, "--feed", "")
+			if err != nil {
+				return nil, err
+			}
+			flags.Feed = val
+			i = newIndex
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("unknown flag: %s", arg)
+		}
+		positional = append(positional, arg)
+	}
+
+	if len(positional) != 1 {
+		return nil, fmt.Errorf("usage: read <post url> [--feed feed_url] [--extract]")
+	}
+	flags.URL = positional[0]
+
+	return flags, nil
+}
+
+// feedActions are the subcommands of feed
+var feedActions = map[string]bool{"history": true, "events": true, "enable": true}
+
+// Parse feed flags: feed history <url> [--limit n] | feed events <url> | feed enable <url>
+func ParseFeedFlags(args []string) (*FeedFlags, error) {
+	const usage = "usage: feed history <feed_url> [--limit n] | feed events <feed_url> | feed enable <feed_url>"
+	flags := &FeedFlags{Limit: defaultHistoryLimit}
+
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--limit" || strings.HasPrefix(arg, "--limit="):
+			val, newIndex, err := parseIntFlag(args, i, "--limit", "")
+			if err != nil {
+				return nil, err
+			}
+			if val < 1 {
+				return nil, fmt.Errorf("--limit must be at least 1")
+			}
+			flags.Limit = val
+			i = newIndex
+		case strings.HasPrefix(arg, "-"):
+			return nil, fmt.Errorf("unknown flag: %s (%s)", arg, usage)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) != 2 || !feedActions[positional[0]] {
+		return nil, fmt.Errorf(usage)
+	}
+	flags.Action = positional[0]
+	flags.URL = positional[1]
+
+	return flags, nil
+}
+
+// Parse refresh flags: refresh <url> | refresh --all
+func ParseRefreshFlags(args []string) (*RefreshFlags, error) {
+	const usage = "usage: refresh <feed_url> or refresh --all"
+	flags := &RefreshFlags{}
+
+	var positional []string
+	for _, arg := range args {
+		switch {
+		case arg == "--all":
+			flags.All = true
+		case strings.HasPrefix(arg, "-"):
+			return nil, fmt.Errorf("unknown flag: %s (%s)", arg, usage)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if flags.All == (len(positional) == 1) || len(positional) > 1 {
+		return nil, fmt.Errorf(usage)
+	}
+	if !flags.All {
+		flags.URL = positional[0]
+	}
+
+	return flags, nil
+}
+
+// Parse podcasts flags: podcasts [list|download] [--feed name] [--dir path] [--keep n] [--limit n]
+func ParsePodcastFlags(args []string) (*PodcastFlags, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get home directory: %w", err)
+	}
+
+	flags := &PodcastFlags{
+		Action: "list",
+		Dir:    filepath.Join(homeDir, ".gator", "podcasts"),
+		Keep:   5,
+		Limit:  20,
+	}
+
+	actionSet := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// Handle --feed or -f
+		if strings.HasPrefix(arg, "--feed") || arg == "-f" {
+			val, newIndex, err := parseFlagValue(args, i, "--feed", "-f")
+			if err != nil {
+				return nil, err
+			}
+			if val != "" {
+				flags.Feed = val
+				i = newIndex
+				continue
+			}
+		}
+
+		// Handle --dir or -d
+		if strings.HasPrefix(arg, "--dir") || arg == "-d" {
+			val, newIndex, err := parseFlagValue(args, i, "--dir", "-d")
+			if err != nil {
+				return nil, err
+			}
+			if val != "" {
+				flags.Dir = val
+				i = newIndex
+				continue
+			}
+		}
+
+		// Handle --keep or -k
+		if strings.HasPrefix(arg, "--keep") || arg == "-k" {
+			val, newIndex, err := parseIntFlag(args, i, "--keep", "-k")
+			if err != nil {
+				return nil, err
+			}
+			if val < 1 {
+				return nil, fmt.Errorf("keep must be >= 1")
+			}
+			flags.Keep = val
+			i = newIndex
+			continue
+		}
+
+		// Handle --limit or -l
+		if strings.HasPrefix(arg, "--limit") || arg == "-l" {
+			val, newIndex, err := parseIntFlag(args, i, "--limit", "-l")
+			if err != nil {
+				return nil, err
+			}
+			if val > 0 {
+				flags.Limit = val
+				i = newIndex
+				continue
+			}
+		}
+
+		// First non-flag argument is the action
+		if !strings.HasPrefix(arg, "-") && !actionSet {
+			flags.Action = arg
+			actionSet = true
+			continue
+		}
+
+		return nil, fmt.Errorf("unknown flag: %s", arg)
+	}
+
+	if flags.Action != "list" && flags.Action != "download" {
+		return nil, fmt.Errorf("invalid action: %s (valid: list, download)", flags.Action)
+	}
+
+	return flags, nil
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+)
+
+const (
+	// defaultDisableAfter is how many fetches in a row may fail before a feed is disabled
+	defaultDisableAfter = 10
+
+	// maxBackoff caps the wait between retries of a failing feed
+	maxBackoff = 7 * 24 * time.Hour
+
+	// maxErrorLength keeps stored error messages readable
+	maxErrorLength = 500
+)
+
+// disableAfter returns the configured failure threshold
+func (cfg *Config) disableAfter() int {
+	if cfg == nil || cfg.DisableAfterFailures <= 0 {
+		return defaultDisableAfter
+	}
+	return cfg.DisableAfterFailures
+}
+
+// truncateError returns err's message cut to maxErrorLength bytes, on a
+// character boundary so the stored text stays valid UTF-8
+func truncateError(err error) string {
+	return truncateUTF8(err.Error(), maxErrorLength)
+}
+
+// recordFetchSuccess resets the feed's failure count
+func recordFetchSuccess(ctx context.Context, s *State, feed database.Feed, result *FetchResult) error {
+	status := int32(200)
+	if result.NotModified {
+		status = 304
+	}
+	return s.Db.RecordFeedSuccess(ctx, database.RecordFeedSuccessParams{
+		ID:         feed.ID,
+		LastStatus: sql.NullInt32{Int32: status, Valid: true},
+	})
+}
+
+// recordFetchFailure stores why a fetch failed and backs the feed off
+// exponentially, disabling it once it has failed too many times in a row
+func recordFetchFailure(ctx context.Context, s *State, feed database.Feed, fetchErr error) error {
+	var status sql.NullInt32
+	var retryAfter time.Duration
+	var httpErr *HTTPError
+	if errors.As(fetchErr, &httpErr) {
+		status = sql.NullInt32{Int32: int32(httpErr.StatusCode), Valid: true}
+		retryAfter = httpErr.RetryAfter
+	}
+
+	minInterval, _ := s.Conf.fetchBounds()
+	failures := int(feed.ConsecutiveFailures) + 1
+	delay := max(failureBackoff(time.Duration(feed.FetchIntervalSeconds)*time.Second, minInterval, failures), retryAfter)
+
+	message := truncateError(fetchErr)
+
+	updated, err := s.Db.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		ID:          feed.ID,
+		LastError:   sql.NullString{String: message, Valid: true},
+		LastStatus:  status,
+		NextFetchAt: sql.NullTime{Time: time.Now().UTC().Add(delay), Valid: true},
+		Column5:     int32(s.Conf.disableAfter()),
+	})
+	if err != nil {
+		return err
+	}
+
+	if status.Int32 == http.StatusGone && !feed.DisabledAt.Valid {
+		return markFeedGone(ctx, s, updated)
+	}
+
+	if updated.DisabledAt.Valid {
+		log.Printf("Feed %s disabled after %d consecutive failures, run 'gator feed enable %s' to retry it", feed.Name, updated.ConsecutiveFailures, feed.Url)
+	} else {
+		log.Printf("Feed %s failed %d times in a row, retrying in %s", feed.Name, updated.ConsecutiveFailures, delay)
+	}
+	return nil
+}
+
+// failureBackoff doubles the wait after every consecutive failure, starting
+// from the feed's usual interval
+func failureBackoff(interval, minInterval time.Duration, failures int) time.Duration {
+	delay := max(interval, minInterval)
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// enableFeed re-enables a disabled feed of the user's and queues it for the next fetch
+func enableFeed(s *State, feedURL string, user database.User) error {
+	feed, err := s.Db.GetFeedByURL(context.Background(), feedURL)
+	if err != nil {
+		return fmt.Errorf("couldn't find feed %s: %w", feedURL, err)
+	}
+	if feed.UserID != user.ID {
+		return fmt.Errorf("only the user who added feed %s can enable it", feed.Name)
+	}
+
+	if err := s.Db.EnableFeed(context.Background(), feed.ID); err != nil {
+		return fmt.Errorf("couldn't enable feed: %w", err)
+	}
+
+	fmt.Printf("Feed %s enabled, it will be fetched on the next aggregator tick\n", feed.Name)
+	return nil
+}
+
+// printFeedHealth prints a health report of every feed
+func printFeedHealth(feeds []database.Feed) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tFEED\tFAILURES\tLAST STATUS\tLAST SUCCESS\tNEXT FETCH\tLAST ERROR")
+	for _, feed := range feeds {
+		lastStatus := "-"
+		if feed.LastStatus.Valid {
+			lastStatus = fmt.Sprint(feed.LastStatus.Int32)
+		}
+		nextFetch := formatHealthTime(feed.NextFetchAt)
+		if feed.DisabledAt.Valid {
+			nextFetch = "never"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			api.FeedStatus(feed),
+			feed.Name,
+			feed.ConsecutiveFailures,
+			lastStatus,
+			formatHealthTime(feed.LastSuccessAt),
+			nextFetch,
+			feed.LastError.String,
+		)
+	}
+	w.Flush()
+}
+
+func formatHealthTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "-"
+	}
+	return t.Time.Format("Jan 2 15:04")
+}
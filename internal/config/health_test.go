@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestFailureBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{name: "first failure waits one interval", interval: time.Hour, failures: 1, want: time.Hour},
+		{name: "doubles per failure", interval: time.Hour, failures: 4, want: 8 * time.Hour},
+		{name: "unscheduled feed starts at the minimum", interval: 0, failures: 2, want: 30 * time.Minute},
+		{name: "capped", interval: 12 * time.Hour, failures: 30, want: maxBackoff},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := failureBackoff(test.interval, 15*time.Minute, test.failures)
+			if got != test.want {
+				t.Fatalf("Expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestTruncateError(t *testing.T) {
+	if got := truncateError(errors.New("unexpected status: 500")); got != "unexpected status: 500" {
+		t.Fatalf("Expected short errors unchanged, got %q", got)
+	}
+
+	// A multi-byte character straddles the limit
+	long := truncateError(errors.New("x" + strings.Repeat("é", maxErrorLength)))
+	if len(long) > maxErrorLength || !utf8.ValidString(long) {
+		t.Fatalf("Expected at most %d bytes of valid UTF-8, got %d bytes", maxErrorLength, len(long))
+	}
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultFetchHistoryDays is how long fetch attempts are kept
+	defaultFetchHistoryDays = 30
+
+	// defaultHistoryLimit is how many attempts `feed history` shows
+	defaultHistoryLimit = 20
+)
+
+// fetchAttempt is what the fetch history records about one fetch
+type fetchAttempt struct {
+	FeedID    uuid.UUID
+	StartedAt time.Time
+	Duration  time.Duration
+	Status    int // zero when no response came back
+	Bytes     int64
+	Items     int
+	NewPosts  int
+	Err       error
+}
+
+// observe notes the response of a fetch, which failed ones may not have
+func (a *fetchAttempt) observe(result *FetchResult, err error) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		a.Status = httpErr.StatusCode
+	}
+	if result == nil {
+		return
+	}
+	a.Status = result.Status
+	a.Bytes = result.Bytes
+	if result.Feed != nil {
+		a.Items = len(result.Feed.Channel.Item)
+	}
+}
+
+// outcome is the attempt's outcome label in the fetch metrics
+func (a fetchAttempt) outcome() string {
+	switch {
+	case a.Err != nil:
+		return metrics.FetchError
+	case a.Status == http.StatusNotModified:
+		return metrics.FetchNotModified
+	default:
+		return metrics.FetchOK
+	}
+}
+
+func recordFetchAttempt(ctx context.Context, s *State, a fetchAttempt) error {
+	var message string
+	if a.Err != nil {
+		message = truncateError(a.Err)
+	}
+
+	return s.Db.CreateFeedFetch(ctx, database.CreateFeedFetchParams{
+		ID:         uuid.New(),
+		FeedID:     a.FeedID,
+		StartedAt:  a.StartedAt,
+		DurationMs: int32(a.Duration / time.Millisecond),
+		Status:     sql.NullInt32{Int32: int32(a.Status), Valid: a.Status != 0},
+		Bytes:      a.Bytes,
+		ItemsSeen:  int32(a.Items),
+		NewPosts:   int32(a.NewPosts),
+		Error:      sql.NullString{String: message, Valid: a.Err != nil},
+	})
+}
+
+// fetchHistoryRetention returns how long fetch attempts are kept
+func (cfg *Config) fetchHistoryRetention() time.Duration {
+	days := defaultFetchHistoryDays
+	if cfg != nil && cfg.FetchHistoryDays > 0 {
+		days = cfg.FetchHistoryDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// pruneFetchHistory deletes fetch attempts older than the retention period
+func pruneFetchHistory(ctx context.Context, s *State) {
+	pruned, err := s.Db.PruneFeedFetches(ctx, time.Now().UTC().Add(-s.Conf.fetchHistoryRetention()))
+	if err != nil {
+		log.Printf("error pruning fetch history: %s", err)
+		return
+	}
+	if pruned > 0 {
+		log.Printf("Pruned %d old fetch history entries", pruned)
+	}
+}
+
+// FeedHandler runs the commands about a single feed: feed history <url> [--limit n],
+// feed events <url> and feed enable <url>
+func FeedHandler(s *State, cmd Command, user database.User) error {
+	flags, err := ParseFeedFlags(cmd.Args)
+	if err != nil {
+		return err
+	}
+
+	switch flags.Action {
+	case "enable":
+		return enableFeed(s, flags.URL, user)
+	case "events":
+		feed, err := userFeedByURL(s, flags.URL, user)
+		if err != nil {
+			return err
+		}
+		events, err := s.Db.GetFeedEvents(context.Background(), database.GetFeedEventsParams{FeedID: feed.ID, Limit: 50})
+		if err != nil {
+			return fmt.Errorf("couldn't get feed events: %w", err)
+		}
+		printFeedEvents(events)
+		return nil
+	}
+
+	feed, err := userFeedByURL(s, flags.URL, user)
+	if err != nil {
+		return err
+	}
+	fetches, err := s.Db.GetFeedFetches(context.Background(), database.GetFeedFetchesParams{
+		FeedID: feed.ID,
+		Limit:  int32(flags.Limit),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get fetch history: %w", err)
+	}
+
+	printFeedFetches(feed, fetches)
+	return nil
+}
+
+// userFeedByURL returns a feed the user added or follows, like the API's feed lookups
+func userFeedByURL(s *State, feedURL string, user database.User) (database.Feed, error) {
+	feed, err := s.Db.GetFeedByURLForUser(context.Background(), database.GetFeedByURLForUserParams{
+		Url:    feedURL,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return feed, fmt.Errorf("feed %s not found among the feeds you added or follow", feedURL)
+	}
+	if err != nil {
+		return feed, fmt.Errorf("couldn't find feed %s: %w", feedURL, err)
+	}
+	return feed, nil
+}
+
+// printFeedFetches prints a feed's fetch attempts, newest first
+func printFeedFetches(feed database.Feed, fetches []database.FeedFetch) {
+	fmt.Printf("%s (%s)\n", feed.Name, feed.Url)
+	if feed.LastSuccessAt.Valid {
+		fmt.Printf("Last success: %s\n", feed.LastSuccessAt.Time.Format("Jan 2, 2006 15:04"))
+	} else {
+		fmt.Println("Last success: never")
+	}
+
+	if len(fetches) == 0 {
+		fmt.Println("No fetches recorded for this feed")
+		return
+	}
+
+	fmt.Printf("%-17s  %6s  %8s  %9s  %5s  %4s\n", "STARTED", "STATUS", "TIME", "SIZE", "ITEMS", "NEW")
+	for _, fetch := range fetches {
+		status := "-"
+		if fetch.Status.Valid {
+			status = fmt.Sprint(fetch.Status.Int32)
+		}
+		fmt.Printf("%-17s  %6s  %8s  %9s  %5d  %4d",
+			fetch.StartedAt.Format("Jan 2, 2006 15:04"),
+			status,
+			(time.Duration(fetch.DurationMs) * time.Millisecond).String(),
+			formatBytes(fetch.Bytes),
+			fetch.ItemsSeen,
+			fetch.NewPosts,
+		)
+		if fetch.Error.Valid {
+			fmt.Printf("  %s", fetch.Error.String)
+		}
+		fmt.Println()
+	}
+}
+
+// formatBytes renders a size like 512 B, 12.3 KB or 4.0 MB
+func formatBytes(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
+)
+
+func TestFetchAttemptObserve(t *testing.T) {
+	const feed = `<rss version="2.0"><channel><item><title>One</title></item><item><title>Two</title></item></channel></rss>`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed":
+			w.Write([]byte(feed))
+		case "/broken":
+			w.Write([]byte("not a feed"))
+		case "/unchanged":
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path        string
+		wantStatus  int
+		wantBytes   int64
+		wantItems   int
+		wantOutcome string
+	}{
+		{path: "/feed", wantStatus: 200, wantBytes: int64(len(feed)), wantItems: 2, wantOutcome: metrics.FetchOK},
+		{path: "/broken", wantStatus: 200, wantBytes: int64(len("not a feed")), wantOutcome: metrics.FetchError},
+		{path: "/unchanged", wantStatus: 304, wantOutcome: metrics.FetchNotModified},
+		{path: "/down", wantStatus: 503, wantOutcome: metrics.FetchError},
+	}
+
+	fetcher := fetch.New(fetch.Policy{})
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			var attempt fetchAttempt
+			result, err := fetchFeed(context.Background(), fetcher, database.Feed{Url: server.URL + test.path}, nil)
+			attempt.observe(result, err)
+			attempt.Err = err
+
+			if attempt.Status != test.wantStatus || attempt.Bytes != test.wantBytes || attempt.Items != test.wantItems {
+				t.Fatalf("Expected status %d, %d bytes and %d items, got %d, %d and %d",
+					test.wantStatus, test.wantBytes, test.wantItems, attempt.Status, attempt.Bytes, attempt.Items)
+			}
+			if got := attempt.outcome(); got != test.wantOutcome {
+				t.Fatalf("Expected outcome %q, got %q", test.wantOutcome, got)
+			}
+		})
+	}
+
+	// No response at all
+	var attempt fetchAttempt
+	attempt.observe(nil, errors.New("dial tcp: connection refused"))
+	if attempt.Status != 0 {
+		t.Fatalf("Expected no status without a response, got %d", attempt.Status)
+	}
+}
+
+func TestParseFeedFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    FeedFlags
+		wantErr bool
+	}{
+		{name: "history", args: []string{"history", "https://example.com/feed"}, want: FeedFlags{Action: "history", URL: "https://example.com/feed", Limit: defaultHistoryLimit}},
+		{name: "limit", args: []string{"history", "https://example.com/feed", "--limit=5"}, want: FeedFlags{Action: "history", URL: "https://example.com/feed", Limit: 5}},
+		{name: "enable", args: []string{"enable", "https://example.com/feed"}, want: FeedFlags{Action: "enable", URL: "https://example.com/feed", Limit: defaultHistoryLimit}},
+		{name: "events", args: []string{"events", "https://example.com/feed"}, want: FeedFlags{Action: "events", URL: "https://example.com/feed", Limit: defaultHistoryLimit}},
+		{name: "missing url", args: []string{"history"}, wantErr: true},
+		{name: "unknown action", args: []string{"purge", "https://example.com/feed"}, wantErr: true},
+		{name: "zero limit", args: []string{"history", "https://example.com/feed", "--limit", "0"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flags, err := ParseFeedFlags(test.args)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %+v", flags)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if *flags != test.want {
+				t.Fatalf("Expected %+v, got %+v", test.want, *flags)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{n: 0, want: "0 B"},
+		{n: 1023, want: "1023 B"},
+		{n: 12595, want: "12.3 KB"},
+		{n: 4 << 20, want: "4.0 MB"},
+	}
+
+	for _, test := range tests {
+		if got := formatBytes(test.n); got != test.want {
+			t.Fatalf("Expected %q for %d, got %q", test.want, test.n, got)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
+	"github.com/eniolaomotee/BlogGator-Go/internal/sanitize"
+	"github.com/google/uuid"
+)
+
+// ingestResult counts what storing a feed's items did
+type ingestResult struct {
+	New       int
+	Updated   int
+	Unchanged int
+}
+
+// newPostBatch turns a feed's items into a single insert, one array entry per item
+func newPostBatch(feedID uuid.UUID, items []RSSItem, fetchedAt time.Time) database.CreatePostsParams {
+	batch := database.CreatePostsParams{
+		CreatedAt: fetchedAt,
+		FeedID:    feedID,
+	}
+	for _, item := range items {
+		pubDate, inferred := itemPublishedAt(item, fetchedAt)
+		body := item.Content
+		if body == "" {
+			body = item.Description
+		}
+		words, minutes := readingStats(body)
+		version := postVersion{
+			Title:       item.Title,
+			Description: sanitize.HTML(item.Description),
+			Content:     sanitize.HTML(item.Content),
+		}
+
+		batch.Ids = append(batch.Ids, uuid.New())
+		batch.Titles = append(batch.Titles, version.Title)
+		batch.Urls = append(batch.Urls, item.Link)
+		batch.Descriptions = append(batch.Descriptions, version.Description)
+		batch.PublishedAts = append(batch.PublishedAts, pubDate)
+		batch.PublishedAtInferreds = append(batch.PublishedAtInferreds, inferred)
+		batch.Contents = append(batch.Contents, version.Content)
+		batch.WordCounts = append(batch.WordCounts, int32(words))
+		batch.ReadingTimes = append(batch.ReadingTimes, int32(minutes))
+		batch.Guids = append(batch.Guids, itemGUID(item))
+		batch.Authors = append(batch.Authors, itemAuthor(item))
+		batch.ContentHashes = append(batch.ContentHashes, version.hash())
+	}
+	return batch
+}
+
+// storePosts stores a feed's items in one transaction: new items are
+// inserted with their enclosures and known ones are checked for edits.
+func storePosts(ctx context.Context, s *State, feedID uuid.UUID, items []RSSItem, fetchedAt time.Time) (ingestResult, error) {
+	var result ingestResult
+	if len(items) == 0 {
+		return result, nil
+	}
+	if s.Conn == nil {
+		return result, fmt.Errorf("no database connection for the ingest transaction")
+	}
+
+	batch := newPostBatch(feedID, items, fetchedAt)
+	byGUID := make(map[string]int, len(items))
+	for i, guid := range batch.Guids {
+		byGUID[guid] = i
+	}
+
+	tx, err := s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+	q := metrics.Queries(tx)
+
+	// Before inserting, match posts stored under their link to the items' GUIDs
+	_, err = q.AdoptLegacyPostGUIDs(ctx, database.AdoptLegacyPostGUIDsParams{
+		Guids:  batch.Guids,
+		Urls:   batch.Urls,
+		FeedID: feedID,
+	})
+	if err != nil {
+		return result, fmt.Errorf("couldn't match stored posts: %w", err)
+	}
+
+	created, err := q.CreatePosts(ctx, batch)
+	if err != nil {
+		return result, fmt.Errorf("couldn't insert posts: %w", err)
+	}
+
+	isNew := make(map[string]bool, len(created))
+	for _, post := range created {
+		isNew[post.Guid] = true
+		item := items[byGUID[post.Guid]]
+		for _, enclosure := range episodeEnclosures(item) {
+			err = q.CreateEnclosure(ctx, database.CreateEnclosureParams{
+				ID:              uuid.New(),
+				CreatedAt:       fetchedAt,
+				UpdatedAt:       fetchedAt,
+				PostID:          post.ID,
+				Url:             enclosure.URL,
+				MimeType:        enclosure.MimeType,
+				LengthBytes:     sql.NullInt64{Int64: enclosure.LengthBytes, Valid: enclosure.LengthBytes > 0},
+				DurationSeconds: sql.NullInt32{Int32: enclosure.DurationSeconds, Valid: enclosure.DurationSeconds > 0},
+			})
+			if err != nil {
+				return result, fmt.Errorf("couldn't create enclosure for %s: %w", item.Link, err)
+			}
+		}
+	}
+
+	// Items that were already stored may have been edited since
+	var known []string
+	for guid := range byGUID {
+		if !isNew[guid] {
+			known = append(known, guid)
+		}
+	}
+	var revised []string
+	if len(known) > 0 {
+		stored, err := q.GetPostsByFeedGUIDs(ctx, database.GetPostsByFeedGUIDsParams{FeedID: feedID, Guids: known})
+		if err != nil {
+			return result, fmt.Errorf("couldn't load stored posts: %w", err)
+		}
+		for _, post := range stored {
+			i := byGUID[post.Guid]
+			version := postVersion{
+				Title:       batch.Titles[i],
+				Description: batch.Descriptions[i],
+				Content:     batch.Contents[i],
+			}
+			changed, err := revisePost(ctx, q, post, version, batch.WordCounts[i], batch.ReadingTimes[i])
+			if err != nil {
+				return result, fmt.Errorf("couldn't update post %s: %w", post.Url, err)
+			}
+			if changed {
+				revised = append(revised, post.Url)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	for _, url := range revised {
+		log.Printf("Post %s was updated by its publisher", url)
+	}
+
+	result.New = len(created)
+	result.Updated = len(revised)
+	result.Unchanged = len(byGUID) - result.New - result.Updated
+	return result, nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewPostBatch(t *testing.T) {
+	feedID := uuid.New()
+	fetchedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	items := []RSSItem{
+		{
+			Title:       "With content",
+			Link:        "https://example.com/1",
+			GUID:        "post-1",
+			Description: `<p onclick="x()">Teaser</p>`,
+			Content:     "<p>The whole post</p><script>alert(1)</script>",
+			PubDate:     "Mon, 02 Jan 2006 15:04:05 +0000",
+			Author:      "ada@example.com (Ada)",
+		},
+		{
+			Title:       "Teaser only",
+			Link:        "https://example.com/2",
+			Description: "Just a teaser",
+		},
+	}
+
+	batch := newPostBatch(feedID, items, fetchedAt)
+
+	if batch.FeedID != feedID || !batch.CreatedAt.Equal(fetchedAt) {
+		t.Fatalf("Expected feed %s fetched at %s, got %s at %s", feedID, fetchedAt, batch.FeedID, batch.CreatedAt)
+	}
+
+	lengths := []int{
+		len(batch.Ids), len(batch.Titles), len(batch.Urls), len(batch.Descriptions),
+		len(batch.PublishedAts), len(batch.PublishedAtInferreds), len(batch.Contents),
+		len(batch.WordCounts), len(batch.ReadingTimes), len(batch.Guids), len(batch.Authors),
+		len(batch.ContentHashes),
+	}
+	for _, n := range lengths {
+		if n != len(items) {
+			t.Fatalf("Expected every column to have %d entries, got %v", len(items), lengths)
+		}
+	}
+
+	if batch.Guids[0] != "post-1" || batch.Guids[1] != "https://example.com/2" {
+		t.Fatalf("Expected the guid, then the link, got %v", batch.Guids)
+	}
+	if batch.Descriptions[0] != "<p>Teaser</p>" || batch.Contents[0] != "<p>The whole post</p>" {
+		t.Fatalf("Expected sanitised bodies, got %q and %q", batch.Descriptions[0], batch.Contents[0])
+	}
+	if batch.Contents[1] != "" || batch.Authors[1] != "" {
+		t.Fatalf("Expected empty content and author, got %q and %q", batch.Contents[1], batch.Authors[1])
+	}
+	if batch.PublishedAtInferreds[0] || !batch.PublishedAtInferreds[1] {
+		t.Fatalf("Expected only the undated item's date to be inferred, got %v", batch.PublishedAtInferreds)
+	}
+
+	version := postVersion{Title: batch.Titles[0], Description: batch.Descriptions[0], Content: batch.Contents[0]}
+	if batch.ContentHashes[0] != version.hash() {
+		t.Fatalf("Expected the hash of the sanitised version")
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// isJSONFeed reports whether the document is a JSON Feed, by content type or by sniffing the body
+func isJSONFeed(data []byte, contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.Contains(contentType, "application/feed+json") || strings.Contains(contentType, "application/json") {
+		return true
+	}
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// parseJSONFeed maps a JSON Feed document onto the RSS model that collectFeed stores
+func parseJSONFeed(data []byte) (*RSSFeed, error) {
+	var feed JSONFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("error unmarshaling json feed %w", err)
+	}
+
+	if !strings.HasPrefix(feed.Version, "https://jsonfeed.org/version/") {
+		return nil, fmt.Errorf("unsupported json feed version %q", feed.Version)
+	}
+
+	var rss RSSFeed
+	rss.Channel.Title = feed.Title
+	rss.Channel.Link = feed.HomePageURL
+	rss.Channel.Description = feed.Description
+	if feed.FeedURL != "" {
+		rss.Channel.AtomLinks = append(rss.Channel.AtomLinks, AtomLink{Rel: "self", Href: feed.FeedURL})
+	}
+	for _, hub := range feed.Hubs {
+		if strings.EqualFold(hub.Type, "websub") && hub.URL != "" {
+			rss.Channel.AtomLinks = append(rss.Channel.AtomLinks, AtomLink{Rel: "hub", Href: hub.URL})
+		}
+	}
+
+	for _, item := range feed.Items {
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+		if link == "" && strings.HasPrefix(item.ID, "http") {
+			link = item.ID
+		}
+
+		description := item.Summary
+		if description == "" {
+			description = item.ContentHTML
+		}
+		if description == "" {
+			description = item.ContentText
+		}
+
+		var enclosures []RSSEnclosure
+		duration := ""
+		for _, attachment := range item.Attachments {
+			enclosure := RSSEnclosure{URL: attachment.URL, Type: attachment.MimeType}
+			if attachment.SizeInBytes > 0 {
+				enclosure.Length = strconv.FormatInt(attachment.SizeInBytes, 10)
+			}
+			enclosures = append(enclosures, enclosure)
+			if duration == "" && attachment.DurationInSeconds > 0 {
+				duration = strconv.Itoa(int(attachment.DurationInSeconds))
+			}
+		}
+
+		content := item.ContentHTML
+		if content == "" {
+			content = item.ContentText
+		}
+
+		rss.Channel.Item = append(rss.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        link,
+			Description: description,
+			Content:     content,
+			PubDate:     item.DatePublished,
+			Updated:     item.DateModified,
+			GUID:        item.ID,
+			Author:      jsonFeedAuthor(item),
+			Enclosures:  enclosures,
+			Duration:    duration,
+		})
+	}
+
+	return &rss, nil
+}
+
+// jsonFeedAuthor joins the item's author names, accepting the 1.0 single author field
+func jsonFeedAuthor(item JSONFeedItem) string {
+	authors := item.Authors
+	if len(authors) == 0 && item.Author != nil {
+		authors = []JSONFeedAuthor{*item.Author}
+	}
+
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		if author.Name != "" {
+			names = append(names, author.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
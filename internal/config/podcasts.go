@@ -0,0 +1,282 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+	"github.com/google/uuid"
+)
+
+// PodcastsHandler lists podcast episodes from followed feeds and downloads them
+func PodcastsHandler(s *State, cmd Command, user database.User) error {
+	flags, err := ParsePodcastFlags(cmd.Args)
+	if err != nil {
+		return err
+	}
+
+	perFeed := flags.Limit
+	if flags.Action == "download" {
+		perFeed = flags.Keep
+	}
+	episodes, err := s.Db.GetEpisodesForUser(context.Background(), database.GetEpisodesForUserParams{
+		UserID:   user.ID,
+		FeedName: flags.Feed,
+		PerFeed:  int32(perFeed),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get episodes: %w", err)
+	}
+
+	if len(episodes) == 0 {
+		fmt.Println("No podcast episodes found. Follow a podcast feed and run agg first")
+		return nil
+	}
+
+	switch flags.Action {
+	case "list":
+		listEpisodes(episodes)
+		return nil
+	case "download":
+		return downloadEpisodes(s.Fetcher, episodes, flags.Dir, flags.Keep)
+	default:
+		return fmt.Errorf("unknown action %s (valid: list, download)", flags.Action)
+	}
+}
+
+func listEpisodes(episodes []database.GetEpisodesForUserRow) {
+	currentFeed := ""
+	for _, episode := range episodes {
+		if episode.FeedName != currentFeed {
+			currentFeed = episode.FeedName
+			fmt.Printf("\n%s\n", currentFeed)
+			fmt.Println(strings.Repeat("-", 50))
+		}
+		fmt.Printf("%s  %s\n", episode.PublishedAt.Time.Format("Jan 2, 2006"), episode.PostTitle)
+		fmt.Printf("    %s\n", describeEnclosure(database.Enclosure{
+			Url:             episode.Url,
+			MimeType:        episode.MimeType,
+			LengthBytes:     episode.LengthBytes,
+			DurationSeconds: episode.DurationSeconds,
+		}))
+	}
+}
+
+// downloadEpisodes downloads the newest keep episodes of every feed into
+// dir/<feed name>/ and removes older episodes already on disk.
+func downloadEpisodes(fetcher *fetch.Fetcher, episodes []database.GetEpisodesForUserRow, dir string, keep int) error {
+	// Episodes arrive ordered by feed then newest first; keep one enclosure per post
+	perFeed := make(map[uuid.UUID][]database.GetEpisodesForUserRow)
+	var feedOrder []uuid.UUID
+	seenPosts := make(map[uuid.UUID]bool)
+	for _, episode := range episodes {
+		if seenPosts[episode.PostID] {
+			continue
+		}
+		seenPosts[episode.PostID] = true
+
+		if _, ok := perFeed[episode.FeedID]; !ok {
+			feedOrder = append(feedOrder, episode.FeedID)
+		}
+		if len(perFeed[episode.FeedID]) < keep {
+			perFeed[episode.FeedID] = append(perFeed[episode.FeedID], episode)
+		}
+	}
+
+	failures := 0
+	for _, feedID := range feedOrder {
+		feedEpisodes := perFeed[feedID]
+		feedDir := filepath.Join(dir, safeFileName(feedEpisodes[0].FeedName))
+		if err := os.MkdirAll(feedDir, 0755); err != nil {
+			return fmt.Errorf("couldn't create %s: %w", feedDir, err)
+		}
+
+		keepFiles := make(map[string]bool)
+		for _, episode := range feedEpisodes {
+			name := episodeFileName(episode)
+			keepFiles[name] = true
+
+			target := filepath.Join(feedDir, name)
+			if _, err := os.Stat(target); err == nil {
+				fmt.Printf("✓ %s (already downloaded)\n", name)
+				continue
+			}
+
+			fmt.Printf("↓ %s\n", name)
+			if err := downloadFile(context.Background(), fetcher, episode.Url, target); err != nil {
+				fmt.Printf("  failed: %v\n", err)
+				failures++
+				// keep the partial file so the next run can resume it
+				keepFiles[name+".part"] = true
+			}
+		}
+
+		if err := pruneEpisodes(feedDir, keepFiles); err != nil {
+			return err
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d episodes failed to download", failures)
+	}
+	return nil
+}
+
+// downloadFile downloads url into target, resuming from target.part when present
+func downloadFile(ctx context.Context, fetcher *fetch.Fetcher, rawURL, target string) error {
+	partial := target + ".part"
+
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	// Episodes can be large, so there's no overall timeout or size limit here
+	client := &http.Client{Transport: fetcher.Transport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// Server ignored the range, start over
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is already complete
+		return os.Rename(partial, target)
+	default:
+		return fmt.Errorf("unexpected status: %d %s", resp.StatusCode, resp.Status)
+	}
+
+	file, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s: %w", partial, err)
+	}
+
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		return fmt.Errorf("download interrupted: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(partial, target)
+}
+
+// episodeManifest lists the files downloaded into a feed's directory, so
+// pruning leaves anything else there alone
+const episodeManifest = ".gator-episodes"
+
+// pruneEpisodes removes the episodes downloaded into dir that aren't being
+// kept, then records the kept ones in the manifest
+func pruneEpisodes(dir string, keep map[string]bool) error {
+	manifest := filepath.Join(dir, episodeManifest)
+	data, err := os.ReadFile(manifest)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("couldn't read %s: %w", manifest, err)
+	}
+
+	for _, name := range strings.Split(string(data), "\n") {
+		// Names come from the file, stay inside dir whatever it says
+		if name == "" || keep[name] || name != filepath.Base(name) {
+			continue
+		}
+		err := os.Remove(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't remove old episode: %w", err)
+		}
+		fmt.Printf("✗ %s (removed, past retention)\n", name)
+	}
+
+	kept := make([]string, 0, len(keep))
+	for name := range keep {
+		kept = append(kept, name)
+	}
+	sort.Strings(kept)
+	if err := os.WriteFile(manifest, []byte(strings.Join(kept, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("couldn't write %s: %w", manifest, err)
+	}
+	return nil
+}
+
+// episodeFileName builds a stable file name from the publish date and title
+func episodeFileName(episode database.GetEpisodesForUserRow) string {
+	ext := ""
+	if u, err := url.Parse(episode.Url); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	if ext == "" || len(ext) > 6 {
+		if exts, _ := mime.ExtensionsByType(episode.MimeType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+
+	date := episode.PublishedAt.Time.Format("2006-01-02")
+	return safeFileName(date+" "+episode.PostTitle) + ext
+}
+
+// safeFileName strips characters that aren't safe in file names
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '-'
+		}
+		if r < 32 {
+			return -1
+		}
+		return r
+	}, name)
+
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	name = truncateUTF8(name, 120)
+	if name == "" {
+		name = "untitled"
+	}
+	return name
+}
+
+// describeEnclosure renders an enclosure as "audio/mpeg • 42.0 MB • 1h2m3s • url"
+func describeEnclosure(enclosure database.Enclosure) string {
+	parts := []string{}
+	if enclosure.MimeType != "" {
+		parts = append(parts, enclosure.MimeType)
+	}
+	if enclosure.LengthBytes.Valid {
+		parts = append(parts, fmt.Sprintf("%.1f MB", float64(enclosure.LengthBytes.Int64)/(1024*1024)))
+	}
+	if enclosure.DurationSeconds.Valid {
+		parts = append(parts, (time.Duration(enclosure.DurationSeconds.Int32) * time.Second).String())
+	}
+	parts = append(parts, enclosure.Url)
+	return strings.Join(parts, " • ")
+}
@@ -0,0 +1,27 @@
+package config
+
+import "strings"
+
+// rdfToRSS maps an RSS 1.0 document onto the RSS model that collectFeed stores
+func rdfToRSS(rdf *RDFFeed) *RSSFeed {
+	var rss RSSFeed
+
+	rss.Channel.Title = rdf.Channel.Title
+	rss.Channel.Link = rdf.Channel.Link
+	rss.Channel.Description = rdf.Channel.Description
+	rss.Channel.UpdatePeriod = rdf.Channel.UpdatePeriod
+	rss.Channel.UpdateFrequency = rdf.Channel.UpdateFrequency
+
+	for _, item := range rdf.Items {
+		rss.Channel.Item = append(rss.Channel.Item, RSSItem{
+			Title:       strings.TrimSpace(item.Title),
+			Link:        strings.TrimSpace(item.Link),
+			Description: item.Description,
+			DCDate:      strings.TrimSpace(item.Date),
+			GUID:        item.About,
+			Author:      strings.TrimSpace(item.Creator),
+		})
+	}
+
+	return &rss
+}
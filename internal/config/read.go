@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/sanitize"
+)
+
+// ReadHandler prints the full text of a post. With --extract the linked page
+// is fetched and its main content stored on the post first.
+func ReadHandler(s *State, cmd Command, user database.User) error {
+	flags, err := ParseReadFlags(cmd.Args)
+	if err != nil {
+		return err
+	}
+
+	post, err := findPost(s, flags, user)
+	if err != nil {
+		return err
+	}
+
+	if flags.Extract {
+		post, err = extractPostArticle(s, post)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(post.Title)
+	fmt.Println(strings.Repeat("=", 50))
+	if post.ReadingTimeMinutes > 0 {
+		fmt.Printf("%d words • %d min read\n\n", post.WordCount, post.ReadingTimeMinutes)
+	}
+	fmt.Println(wordWrap(sanitize.Text(postBody(post.ArticleHtml, post.Content, post.Description)), 80))
+	fmt.Printf("\nLink: %s\n", post.Url)
+	return nil
+}
+
+// findPost returns the post linking to flags.URL among the feeds the user
+// follows. When several of them posted the link, --feed says which one is meant.
+func findPost(s *State, flags *ReadFlags, user database.User) (database.Post, error) {
+	posts, err := s.Db.GetPostsByURLForUser(context.Background(), database.GetPostsByURLForUserParams{
+		Url:    flags.URL,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return database.Post{}, fmt.Errorf("couldn't get post: %w", err)
+	}
+
+	if flags.Feed != "" {
+		feed, err := s.Db.GetFeedByURL(context.Background(), flags.Feed)
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Post{}, fmt.Errorf("no feed with url %s", flags.Feed)
+		}
+		if err != nil {
+			return database.Post{}, fmt.Errorf("couldn't get feed: %w", err)
+		}
+		var fromFeed []database.Post
+		for _, post := range posts {
+			if post.FeedID == feed.ID {
+				fromFeed = append(fromFeed, post)
+			}
+		}
+		posts = fromFeed
+	}
+
+	switch len(posts) {
+	case 0:
+		return database.Post{}, fmt.Errorf("no post with url %s in the feeds you follow, run agg first", flags.URL)
+	case 1:
+		return posts[0], nil
+	}
+
+	feeds := make([]string, 0, len(posts))
+	for _, post := range posts {
+		feed, err := s.Db.GetFeedByID(context.Background(), post.FeedID)
+		if err != nil {
+			return database.Post{}, fmt.Errorf("couldn't get feed: %w", err)
+		}
+		feeds = append(feeds, feed.Url)
+	}
+	return database.Post{}, fmt.Errorf("%s was posted by %d feeds, pass --feed with one of: %s",
+		flags.URL, len(posts), strings.Join(feeds, ", "))
+}
+
+// extractPostArticle fetches the post's page, runs the readability extractor
+// and stores the article along with its word count and reading time
+func extractPostArticle(s *State, post database.Post) (database.Post, error) {
+	article, err := fetchArticle(context.Background(), s.Fetcher, post.Url)
+	if err != nil {
+		return post, fmt.Errorf("couldn't extract article: %w", err)
+	}
+
+	article = sanitize.HTML(article)
+	words, minutes := readingStats(article)
+	post.ArticleHtml = sql.NullString{String: article, Valid: true}
+	post.WordCount = int32(words)
+	post.ReadingTimeMinutes = int32(minutes)
+
+	err = s.Db.UpdatePostArticle(context.Background(), database.UpdatePostArticleParams{
+		ID:                 post.ID,
+		ArticleHtml:        post.ArticleHtml,
+		WordCount:          post.WordCount,
+		ReadingTimeMinutes: post.ReadingTimeMinutes,
+	})
+	if err != nil {
+		return post, fmt.Errorf("couldn't save article: %w", err)
+	}
+	return post, nil
+}
+
+// postBody picks the fullest version of a post: the extracted article, the
+// feed's full content, or the description
+func postBody(article, content, description sql.NullString) string {
+	for _, body := range []sql.NullString{article, content} {
+		if body.Valid && body.String != "" {
+			return body.String
+		}
+	}
+	return description.String
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+	"github.com/eniolaomotee/BlogGator-Go/internal/sanitize"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// wordsPerMinute is the reading speed used for reading time estimates
+const wordsPerMinute = 200
+
+// strippedTags never contain article text
+var strippedTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Input:    true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Svg:      true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+}
+
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)banner|breadcrumb|comment|cookie|disqus|footer|masthead|menu|modal|nav|newsletter|popup|promo|related|share|sidebar|social|sponsor|subscribe|advert|\bads?\b`)
+	likelyCandidates   = regexp.MustCompile(`(?i)article|body|content|entry|main|post|story|text`)
+)
+
+// fetchArticle downloads the page a post links to and extracts its main content
+func fetchArticle(ctx context.Context, fetcher *fetch.Fetcher, pageURL string) (string, error) {
+	data, contentType, _, err := getDocument(ctx, fetcher, pageURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if !isHTML(data, contentType) {
+		return "", fmt.Errorf("%s is not an HTML page", pageURL)
+	}
+
+	data, err = toUTF8(data, contentType)
+	if err != nil {
+		return "", err
+	}
+	return extractArticle(data)
+}
+
+// extractArticle finds the element holding the page's main text and returns
+// it as HTML. Paragraphs score their parent and grandparent by length and
+// comma count, the way readability does, and link-heavy blocks are penalised.
+func extractArticle(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("error parsing html %w", err)
+	}
+
+	removeClutter(doc)
+
+	// Candidates are kept in document order so ties go to the first one
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	addScore := func(n *html.Node, score float64) {
+		if _, ok := scores[n]; !ok {
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre || n.DataAtom == atom.Blockquote) {
+			text := strings.TrimSpace(nodeText(n))
+			if len(text) >= 25 && n.Parent != nil {
+				score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+				if n.Parent.Parent != nil {
+					addScore(n.Parent.Parent, score/2)
+				}
+				addScore(n.Parent, score)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, node := range candidates {
+		score := scores[node] + classWeight(node)
+		score *= 1 - linkDensity(node)
+		if best == nil || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+
+	if best == nil {
+		best = firstElement(doc, atom.Article)
+	}
+	if best == nil {
+		best = firstElement(doc, atom.Main)
+	}
+	if best == nil {
+		best = firstElement(doc, atom.Body)
+	}
+	if best == nil || strings.TrimSpace(nodeText(best)) == "" {
+		return "", fmt.Errorf("no readable content found")
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, best); err != nil {
+		return "", fmt.Errorf("error rendering article %w", err)
+	}
+	return buf.String(), nil
+}
+
+// removeClutter drops elements that never hold the article, like scripts,
+// navigation and anything whose class or id looks like a sidebar or share bar
+func removeClutter(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && isClutter(c)) {
+			n.RemoveChild(c)
+		} else {
+			removeClutter(c)
+		}
+		c = next
+	}
+}
+
+func isClutter(n *html.Node) bool {
+	if strippedTags[n.DataAtom] {
+		return true
+	}
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		return false
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyCandidates.MatchString(names) && !likelyCandidates.MatchString(names)
+}
+
+// classWeight nudges the score of elements whose class or id hint at their role
+func classWeight(n *html.Node) float64 {
+	names := attr(n, "class") + " " + attr(n, "id")
+	weight := 0.0
+	if likelyCandidates.MatchString(names) {
+		weight += 25
+	}
+	if unlikelyCandidates.MatchString(names) {
+		weight -= 25
+	}
+	if n.DataAtom == atom.Article {
+		weight += 10
+	}
+	return weight
+}
+
+// linkDensity is the share of the node's text that sits inside links
+func linkDensity(n *html.Node) float64 {
+	total := len(nodeText(n))
+	if total == 0 {
+		return 0
+	}
+
+	linked := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			linked += len(nodeText(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return float64(linked) / float64(total)
+}
+
+func firstElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := firstElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// nodeText concatenates all the text below n
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var s strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		s.WriteString(nodeText(c))
+	}
+	return s.String()
+}
+
+// readingStats counts the words in an HTML body and estimates the minutes needed to read them
+func readingStats(body string) (words, minutes int) {
+	words = sanitize.WordCount(body)
+	if words == 0 {
+		return 0, 0
+	}
+	return words, (words + wordsPerMinute - 1) / wordsPerMinute
+}
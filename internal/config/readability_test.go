@@ -0,0 +1,58 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractArticle(t *testing.T) {
+	page := `<html><head><title>Post</title><script>track()</script></head><body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<div class="sidebar"><p>Subscribe to our newsletter, it is great, really, honestly.</p></div>
+<div class="post-content">
+<h1>Why feeds matter</h1>
+<p>Feeds let readers follow a site without an algorithm deciding what they see, which is the whole point.</p>
+<p>They are simple, cheap to serve, and supported by nearly every blogging engine out there.</p>
+</div>
+<footer><p>Copyright 2024, all rights reserved, every single one of them.</p></footer>
+</body></html>`
+
+	article, err := extractArticle([]byte(page))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(article, "Feeds let readers follow a site") {
+		t.Fatalf("Expected article body, got %q", article)
+	}
+	for _, clutter := range []string{"newsletter", "Copyright", "track()", "About"} {
+		if strings.Contains(article, clutter) {
+			t.Fatalf("Expected %q to be stripped, got %q", clutter, article)
+		}
+	}
+}
+
+func TestExtractArticleTie(t *testing.T) {
+	const text = "An equally long paragraph, scored the same as the other one."
+	page := `<html><body>
+<div><div id="first"><p>` + text + `</p></div></div>
+<div><div id="second"><p>` + text + `</p></div></div>
+</body></html>`
+
+	// Map order would pick either one; the first in the document must win every time
+	for i := 0; i < 20; i++ {
+		article, err := extractArticle([]byte(page))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !strings.Contains(article, `id="first"`) {
+			t.Fatalf("Expected the first candidate on a tie, got %q", article)
+		}
+	}
+}
+
+func TestReadingStats(t *testing.T) {
+	words, minutes := readingStats("<p>" + strings.Repeat("word ", 401) + "</p><script>not counted</script>")
+	if words != 401 || minutes != 3 {
+		t.Fatalf("Expected 401 words and 3 minutes, got %d and %d", words, minutes)
+	}
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
+	"github.com/google/uuid"
+)
+
+// Events recorded in a feed's audit trail
+const (
+	feedEventMoved  = "moved"
+	feedEventMerged = "merged"
+	feedEventGone   = "gone"
+	feedEventKept   = "kept" // a move that wasn't merged
+)
+
+func isPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// applyFeedMove points a feed at the URL it permanently redirects to. When
+// that URL is already registered, the feeds are merged: follows and posts move
+// to the existing feed and this one is deleted. Returns the feed that remains.
+func applyFeedMove(ctx context.Context, s *State, feed database.Feed, newURL string) (database.Feed, error) {
+	if newURL == "" || newURL == feed.Url {
+		return feed, nil
+	}
+	if s.Conn == nil {
+		return feed, fmt.Errorf("no database connection for the move transaction")
+	}
+
+	tx, err := s.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return feed, err
+	}
+	defer tx.Rollback()
+	q := metrics.Queries(tx)
+
+	target, err := q.GetFeedByURL(ctx, newURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = q.UpdateFeedURL(ctx, database.UpdateFeedURLParams{ID: feed.ID, Url: newURL})
+		if err != nil {
+			return feed, err
+		}
+		err = recordFeedEvent(ctx, q, feed.ID, feedEventMoved, feed.Url, newURL, "")
+		if err != nil {
+			return feed, err
+		}
+		if err := tx.Commit(); err != nil {
+			return feed, err
+		}
+
+		log.Printf("Feed %s moved permanently from %s to %s", feed.Name, feed.Url, newURL)
+		feed.Url = newURL
+		return feed, nil
+	}
+	if err != nil {
+		return feed, err
+	}
+
+	// Merging would share posts fetched with one owner's credentials with the
+	// other feed's followers, so such feeds stay apart
+	private, err := mergeSharesCredentials(ctx, q, feed, target)
+	if err != nil {
+		return feed, err
+	}
+	if private {
+		if err := recordMergeRefused(ctx, q, feed, target); err != nil {
+			return feed, err
+		}
+		if err := tx.Commit(); err != nil {
+			return feed, err
+		}
+		log.Printf("Feed %s moved to %s, registered by another user as %s; not merging because one of them has credentials",
+			feed.Name, newURL, target.Name)
+		return feed, nil
+	}
+
+	err = q.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{FeedID: feed.ID, FeedID_2: target.ID})
+	if err != nil {
+		return feed, err
+	}
+	// Posts the target already has stay behind and go with the old feed
+	err = q.MoveFeedPosts(ctx, database.MoveFeedPostsParams{FeedID: feed.ID, FeedID_2: target.ID})
+	if err != nil {
+		return feed, err
+	}
+	err = q.DeleteFeed(ctx, feed.ID)
+	if err != nil {
+		return feed, err
+	}
+	err = recordFeedEvent(ctx, q, target.ID, feedEventMerged, feed.Url, newURL, fmt.Sprintf("merged feed %q into this one", feed.Name))
+	if err != nil {
+		return feed, err
+	}
+	if err := tx.Commit(); err != nil {
+		return feed, err
+	}
+
+	log.Printf("Feed %s moved to %s, which is already registered as %s; merged them", feed.Name, newURL, target.Name)
+	return target, nil
+}
+
+// mergeSharesCredentials reports whether merging two feeds of different owners
+// would move posts or followers across a feed with credentials
+func mergeSharesCredentials(ctx context.Context, q *database.Queries, feed, target database.Feed) (bool, error) {
+	if feed.UserID == target.UserID {
+		return false, nil
+	}
+	for _, id := range []uuid.UUID{feed.ID, target.ID} {
+		private, err := q.FeedHasCredentials(ctx, id)
+		if err != nil || private {
+			return private, err
+		}
+	}
+	return false, nil
+}
+
+// recordMergeRefused notes on the feed that it wasn't merged, once rather than on every fetch
+func recordMergeRefused(ctx context.Context, q *database.Queries, feed, target database.Feed) error {
+	events, err := q.GetFeedEvents(ctx, database.GetFeedEventsParams{FeedID: feed.ID, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(events) == 1 && events[0].Event == feedEventKept && events[0].NewUrl.String == target.Url {
+		return nil
+	}
+	detail := fmt.Sprintf("not merged into feed %q, one of them has credentials", target.Name)
+	return recordFeedEvent(ctx, q, feed.ID, feedEventKept, feed.Url, target.Url, detail)
+}
+
+// markFeedGone disables a feed whose server answered 410 Gone
+func markFeedGone(ctx context.Context, s *State, feed database.Feed) error {
+	if err := s.Db.DisableFeed(ctx, feed.ID); err != nil {
+		return err
+	}
+	log.Printf("Feed %s is gone (410), disabled it", feed.Name)
+	return recordFeedEvent(ctx, s.Db, feed.ID, feedEventGone, feed.Url, "", "server answered 410 Gone")
+}
+
+func recordFeedEvent(ctx context.Context, q *database.Queries, feedID uuid.UUID, event, oldURL, newURL, detail string) error {
+	return q.CreateFeedEvent(ctx, database.CreateFeedEventParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		FeedID:    feedID,
+		Event:     event,
+		OldUrl:    sql.NullString{String: oldURL, Valid: oldURL != ""},
+		NewUrl:    sql.NullString{String: newURL, Valid: newURL != ""},
+		Detail:    sql.NullString{String: detail, Valid: detail != ""},
+	})
+}
+
+// printFeedEvents prints a feed's audit trail, newest first
+func printFeedEvents(events []database.FeedEvent) {
+	if len(events) == 0 {
+		fmt.Println("No events recorded for this feed")
+		return
+	}
+	for _, event := range events {
+		fmt.Printf("%s  %-7s", event.CreatedAt.Format("Jan 2, 2006 15:04"), event.Event)
+		if event.OldUrl.Valid && event.NewUrl.Valid {
+			fmt.Printf("  %s -> %s", event.OldUrl.String, event.NewUrl.String)
+		}
+		if event.Detail.Valid {
+			fmt.Printf("  (%s)", event.Detail.String)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
+)
+
+func TestFetchFeedRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<rss version="2.0"><channel><title>Moved</title></channel></rss>`))
+	})
+	mux.Handle("/old", http.RedirectHandler("/feed", http.StatusMovedPermanently))
+	mux.Handle("/older", http.RedirectHandler("/old", http.StatusPermanentRedirect))
+	mux.Handle("/temporary", http.RedirectHandler("/old", http.StatusFound))
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := fetch.New(fetch.Policy{})
+
+	tests := []struct {
+		name        string
+		path        string
+		wantMovedTo string
+		wantStatus  int
+	}{
+		{name: "no redirect", path: "/feed"},
+		{name: "301", path: "/old", wantMovedTo: server.URL + "/feed"},
+		{name: "chain of permanent redirects", path: "/older", wantMovedTo: server.URL + "/feed"},
+		{name: "temporary redirect first", path: "/temporary"},
+		{name: "410 gone", path: "/gone", wantStatus: http.StatusGone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := fetchFeed(context.Background(), fetcher, database.Feed{Url: server.URL + test.path}, nil)
+			if test.wantStatus != 0 {
+				var httpErr *HTTPError
+				if !errors.As(err, &httpErr) || httpErr.StatusCode != test.wantStatus {
+					t.Fatalf("Expected status %d, got %v", test.wantStatus, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.MovedTo != test.wantMovedTo {
+				t.Fatalf("Expected moved to %q, got %q", test.wantMovedTo, result.MovedTo)
+			}
+		})
+	}
+}
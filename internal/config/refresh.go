@@ -0,0 +1,167 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/google/uuid"
+)
+
+// refreshCooldown is how long a user waits between on-demand refreshes
+const refreshCooldown = time.Minute
+
+// Why a refresh skipped a feed
+const (
+	skippedInProgress = "already being fetched"
+	skippedDisabled   = "disabled, enable it first"
+)
+
+// RefreshHandler fetches feeds right away: refresh <feed_url> or refresh --all
+func RefreshHandler(s *State, cmd Command, user database.User) error {
+	flags, err := ParseRefreshFlags(cmd.Args)
+	if err != nil {
+		return err
+	}
+
+	var feeds []database.Feed
+	if flags.All {
+		// The feeds the user added or follows
+		feeds, err = s.Db.GetFeedHealthForUser(context.Background(), user.ID)
+		if err != nil {
+			return fmt.Errorf("couldn't get feeds: %w", err)
+		}
+		if len(feeds) == 0 {
+			fmt.Println("No feeds to refresh")
+			return nil
+		}
+	} else {
+		// Like the API, only feeds the user added or follows
+		feed, err := userFeedByURL(s, flags.URL, user)
+		if err != nil {
+			return err
+		}
+		feeds = []database.Feed{feed}
+	}
+
+	results, err := refreshFeeds(context.Background(), s, user.ID, feeds)
+	if err != nil {
+		return err
+	}
+	return printRefreshResults(results)
+}
+
+// refreshFeeds fetches the feeds now through the aggregator's workers. Feeds an
+// aggregator, or another refresh, is already fetching are skipped rather than
+// fetched twice, and each user gets one refresh per refreshCooldown. Only a
+// refresh that fetches something counts towards the cooldown.
+func refreshFeeds(ctx context.Context, s *State, userID uuid.UUID, feeds []database.Feed) ([]api.RefreshResult, error) {
+	owner := aggregatorID()
+	results := make([]api.RefreshResult, len(feeds))
+	index := make(map[uuid.UUID]int, len(feeds))
+	var claimed []database.Feed
+	for i, feed := range feeds {
+		results[i] = api.RefreshResult{FeedID: feed.ID.String(), Name: feed.Name, URL: feed.Url}
+		if feed.DisabledAt.Valid {
+			results[i].Skipped = skippedDisabled
+			continue
+		}
+
+		feed, err := s.Db.ClaimFeed(ctx, database.ClaimFeedParams{
+			Owner:        owner,
+			LeaseSeconds: int32(feedLease / time.Second),
+			ID:           feed.ID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			results[i].Skipped = skippedInProgress
+			continue
+		}
+		if err != nil {
+			results[i].Error = fmt.Sprintf("couldn't claim feed: %s", err)
+			continue
+		}
+		index[feed.ID] = i
+		claimed = append(claimed, feed)
+	}
+	if len(claimed) == 0 {
+		return results, nil
+	}
+	if err := takeRefreshSlot(ctx, s, userID); err != nil {
+		for _, feed := range claimed {
+			releaseFeed(s.Db, owner, feed)
+		}
+		return nil, err
+	}
+
+	feedChan := make(chan database.Feed, len(claimed))
+	done := make(chan feedResult, len(claimed))
+	var wg sync.WaitGroup
+	for i := 0; i < min(numWorkers, len(claimed)); i++ {
+		wg.Add(1)
+		go worker(ctx, i, s, owner, feedChan, done, &wg)
+	}
+	for _, feed := range claimed {
+		feedChan <- feed
+	}
+	close(feedChan)
+	wg.Wait()
+	close(done)
+
+	for result := range done {
+		i := index[result.Feed.ID]
+		if result.Err != nil {
+			results[i].Error = result.Err.Error()
+			continue
+		}
+		results[i].NewPosts = result.Stored.New
+		results[i].UpdatedPosts = result.Stored.Updated
+	}
+	return results, nil
+}
+
+// takeRefreshSlot records the user's refresh, or returns a *api.RefreshLimitError
+// when their last one was less than refreshCooldown ago
+func takeRefreshSlot(ctx context.Context, s *State, userID uuid.UUID) error {
+	cooldown := int32(refreshCooldown / time.Second)
+	_, err := s.Db.TakeRefreshSlot(ctx, database.TakeRefreshSlotParams{UserID: userID, CooldownSeconds: cooldown})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("couldn't check refresh limit: %w", err)
+	}
+
+	wait, err := s.Db.GetRefreshWait(ctx, database.GetRefreshWaitParams{CooldownSeconds: cooldown, UserID: userID})
+	if err != nil {
+		return fmt.Errorf("couldn't check refresh limit: %w", err)
+	}
+	return &api.RefreshLimitError{RetryAfter: time.Duration(max(wait, 1)) * time.Second}
+}
+
+// printRefreshResults prints what each feed produced, failing when any fetch failed
+func printRefreshResults(results []api.RefreshResult) error {
+	newPosts, failures := 0, 0
+	for _, result := range results {
+		switch {
+		case result.Skipped != "":
+			fmt.Printf("  - %s: %s\n", result.Name, result.Skipped)
+		case result.Error != "":
+			failures++
+			fmt.Printf("  ✗ %s: %s\n", result.Name, result.Error)
+		default:
+			newPosts += result.NewPosts
+			fmt.Printf("  ✓ %s: %d new posts, %d updated\n", result.Name, result.NewPosts, result.UpdatedPosts)
+		}
+	}
+	fmt.Printf("Refreshed %d feeds: %d new posts\n", len(results), newPosts)
+
+	if failures > 0 {
+		return fmt.Errorf("%d of %d feeds failed to refresh", failures, len(results))
+	}
+	return nil
+}
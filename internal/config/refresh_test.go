@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+)
+
+func TestParseRefreshFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    RefreshFlags
+		wantErr bool
+	}{
+		{name: "url", args: []string{"https://example.com/feed"}, want: RefreshFlags{URL: "https://example.com/feed"}},
+		{name: "all", args: []string{"--all"}, want: RefreshFlags{All: true}},
+		{name: "nothing", args: []string{}, wantErr: true},
+		{name: "url and all", args: []string{"https://example.com/feed", "--all"}, wantErr: true},
+		{name: "two urls", args: []string{"https://a.example.com/feed", "https://b.example.com/feed"}, wantErr: true},
+		{name: "unknown flag", args: []string{"--force"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flags, err := ParseRefreshFlags(test.args)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %+v", flags)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if *flags != test.want {
+				t.Fatalf("Expected %+v, got %+v", test.want, *flags)
+			}
+		})
+	}
+}
+
+func TestPrintRefreshResults(t *testing.T) {
+	ok := api.RefreshResult{Name: "Go Blog", NewPosts: 2}
+	busy := api.RefreshResult{Name: "Busy", Skipped: skippedInProgress}
+	broken := api.RefreshResult{Name: "Broken", Error: "unexpected status: 500"}
+
+	if err := printRefreshResults([]api.RefreshResult{ok, busy}); err != nil {
+		t.Fatalf("Expected skipped feeds not to fail the refresh, got %s", err)
+	}
+	if err := printRefreshResults([]api.RefreshResult{ok, broken}); err == nil {
+		t.Fatalf("Expected an error when a feed fails")
+	}
+}
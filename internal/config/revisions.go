@@ -0,0 +1,90 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/google/uuid"
+)
+
+// postVersion is the part of a post a publisher can edit, as stored (sanitised)
+type postVersion struct {
+	Title       string
+	Description string
+	Content     string
+}
+
+func storedVersion(post database.Post) postVersion {
+	return postVersion{
+		Title:       post.Title,
+		Description: post.Description.String,
+		Content:     post.Content.String,
+	}
+}
+
+// hash fingerprints the version so edits can be spotted without comparing bodies
+func (v postVersion) hash() string {
+	sum := sha256.Sum256([]byte(v.Title + "\x00" + v.Description + "\x00" + v.Content))
+	return hex.EncodeToString(sum[:])
+}
+
+// changedFields lists the fields that differ between two versions
+func changedFields(old, new postVersion) []string {
+	var changed []string
+	if old.Title != new.Title {
+		changed = append(changed, "title")
+	}
+	if old.Description != new.Description {
+		changed = append(changed, "description")
+	}
+	if old.Content != new.Content {
+		changed = append(changed, "content")
+	}
+	return changed
+}
+
+// revisePost compares a stored post with the version just fetched. When the
+// publisher changed it, the stored version is kept as a revision and the post
+// is updated. Returns whether the post changed.
+func revisePost(ctx context.Context, q *database.Queries, post database.Post, version postVersion, words, minutes int32) (bool, error) {
+	hash := version.hash()
+	if post.ContentHash == "" {
+		// Stored before posts were hashed, and before they were sanitised, so
+		// comparing would flag every one as edited. Take this version as the baseline.
+		err := q.UpdatePostContentHash(ctx, database.UpdatePostContentHashParams{ID: post.ID, ContentHash: hash})
+		return false, err
+	}
+	if post.ContentHash == hash {
+		return false, nil
+	}
+
+	err := q.CreatePostRevision(ctx, database.CreatePostRevisionParams{
+		ID:          uuid.New(),
+		PostID:      post.ID,
+		Title:       post.Title,
+		Description: post.Description,
+		Content:     post.Content,
+		ContentHash: post.ContentHash,
+		Changed:     strings.Join(changedFields(storedVersion(post), version), ","),
+	})
+	if err != nil {
+		return false, err
+	}
+	err = q.RevisePost(ctx, database.RevisePostParams{
+		ID:                 post.ID,
+		Title:              version.Title,
+		Description:        sql.NullString{String: version.Description, Valid: true},
+		Content:            sql.NullString{String: version.Content, Valid: version.Content != ""},
+		ContentHash:        hash,
+		WordCount:          words,
+		ReadingTimeMinutes: minutes,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
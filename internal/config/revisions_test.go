@@ -0,0 +1,57 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPostVersionHash(t *testing.T) {
+	base := postVersion{Title: "Hello", Description: "<p>Teaser</p>", Content: "<p>Body</p>"}
+
+	if base.hash() != base.hash() {
+		t.Fatalf("Expected the hash to be stable")
+	}
+
+	tests := []struct {
+		name    string
+		version postVersion
+	}{
+		{name: "title", version: postVersion{Title: "Hello!", Description: base.Description, Content: base.Content}},
+		{name: "description", version: postVersion{Title: base.Title, Description: "<p>Teaser.</p>", Content: base.Content}},
+		{name: "content", version: postVersion{Title: base.Title, Description: base.Description, Content: "<p>Body, edited</p>"}},
+		// Text moving from one field to the next is still an edit
+		{name: "field boundary", version: postVersion{Title: "Hello<p>Teaser</p>", Description: "", Content: base.Content}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.version.hash() == base.hash() {
+				t.Fatalf("Expected a different hash when the %s changes", test.name)
+			}
+		})
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	old := postVersion{Title: "Hello", Description: "Teaser", Content: "Body"}
+
+	tests := []struct {
+		name    string
+		version postVersion
+		want    []string
+	}{
+		{name: "unchanged", version: old, want: nil},
+		{name: "title", version: postVersion{Title: "Hi", Description: "Teaser", Content: "Body"}, want: []string{"title"}},
+		{name: "body", version: postVersion{Title: "Hello", Description: "Teaser!", Content: "Body!"}, want: []string{"description", "content"}},
+		{name: "content removed", version: postVersion{Title: "Hello", Description: "Teaser"}, want: []string{"content"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := changedFields(old, test.version)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("Expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -1,48 +1,132 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"html"
 	"io"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/fetch"
 )
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+// HTTPError is returned by fetchFeed when the server answers with an unexpected status
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration // from the Retry-After header, zero when absent
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected status: %d %s", e.StatusCode, e.Status)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+// fetchFeed downloads and parses a feed. When the body can't be parsed the
+// result is returned along with the error, for its status and size.
+func fetchFeed(ctx context.Context, fetcher *fetch.Fetcher, feed database.Feed, creds *api.FeedCredentials) (*FetchResult, error) {
+
+	req, err := http.NewRequestWithContext(ctx, "GET", feed.Url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request %w", err)
 	}
 
-	req.Header.Set("User-Agent", "gator")
+	creds.Apply(req)
 
-	client := &http.Client{}
+	// Conditional GET: let the publisher answer 304 when nothing changed
+	if feed.Etag.Valid && feed.Etag.String != "" {
+		req.Header.Set("If-None-Match", feed.Etag.String)
+	}
+	if feed.LastModified.Valid && feed.LastModified.String != "" {
+		req.Header.Set("If-Modified-Since", feed.LastModified.String)
+	}
+
+	// Follow redirects, remembering where an unbroken chain of permanent ones leads
+	movedTo := ""
+	permanent := true
+	client, err := feedClient(fetcher, creds, func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		if permanent && req.Response != nil && isPermanentRedirect(req.Response.StatusCode) {
+			movedTo = req.URL.String()
+		} else {
+			permanent = false
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error sending request %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status: %d %s", resp.StatusCode, resp.Status)
+	defer resp.Body.Close()
 
+	result := &FetchResult{
+		Status:       resp.StatusCode,
+		MovedTo:      movedTo,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		MaxAge:       parseMaxAge(resp.Header.Get("Cache-Control")),
 	}
 
-	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		// A 304 may omit the validators, keep the ones we sent
+		result.NotModified = true
+		if result.ETag == "" {
+			result.ETag = feed.Etag.String
+		}
+		if result.LastModified == "" {
+			result.LastModified = feed.LastModified.String
+		}
+		return result, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
 
-	data, err := io.ReadAll(resp.Body)
+	data, err := fetcher.ReadAll(resp)
 	if err != nil {
 		return nil, fmt.Errorf("error reading data from response %w", err)
 	}
+	result.Bytes = int64(len(data))
 
-	var rss RSSFeed
-
-	err = xml.Unmarshal(data, &rss)
+	rss, err := parseFeed(data, resp.Header.Get("Content-Type"))
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling xml %w", err)
+		return result, err
 	}
 
+	prepareFeed(rss)
+
+	result.Feed = rss
+	result.Hub, result.Topic = feedHub(resp.Header, rss)
+	if result.Hub != "" && result.Topic == "" {
+		result.Topic = feed.Url
+	}
+	return result, nil
+
+}
+
+// prepareFeed drops repeated items and unescapes titles and descriptions,
+// for polled and pushed documents alike
+func prepareFeed(rss *RSSFeed) {
+	rss.Channel.Item = dedupeItems(rss.Channel.Item)
+
 	for i := range rss.Channel.Item {
 		rss.Channel.Item[i].Title = html.UnescapeString(rss.Channel.Item[i].Title)
 		rss.Channel.Item[i].Description = html.UnescapeString(rss.Channel.Item[i].Description)
@@ -53,7 +137,106 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 
 	rss.Channel.Description = channel_desc
 	rss.Channel.Title = channel_title
+}
 
-	return &rss, nil
+// parseFeed detects the feed format and maps it onto RSSFeed
+func parseFeed(data []byte, contentType string) (*RSSFeed, error) {
+	data, err := toUTF8(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if isJSONFeed(data, contentType) {
+		return parseJSONFeed(data)
+	}
 
+	root, err := xmlRootName(data)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling xml %w", err)
+	}
+
+	switch root.Local {
+	case "feed":
+		var atom AtomFeed
+		if err := newFeedDecoder(data).Decode(&atom); err != nil {
+			return nil, fmt.Errorf("error unmarshaling atom %w", err)
+		}
+		return atomToRSS(&atom), nil
+	case "RDF":
+		var rdf RDFFeed
+		if err := newFeedDecoder(data).Decode(&rdf); err != nil {
+			return nil, fmt.Errorf("error unmarshaling rdf %w", err)
+		}
+		return rdfToRSS(&rdf), nil
+	case "rss":
+		var rss RSSFeed
+		if err := newFeedDecoder(data).Decode(&rss); err != nil {
+			return nil, fmt.Errorf("error unmarshaling xml %w", err)
+		}
+		return &rss, nil
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root.Local)
+	}
+}
+
+// newFeedDecoder returns the xml decoder shared by every XML feed format.
+// The data has already been converted to UTF-8 by toUTF8, so whatever
+// encoding the XML declaration names is read as-is.
+func newFeedDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+// xmlRootName returns the name of the first element in the document
+func xmlRootName(data []byte) (xml.Name, error) {
+	decoder := newFeedDecoder(data)
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// dedupeItems drops repeated items within a single document, whatever its format
+func dedupeItems(items []RSSItem) []RSSItem {
+	seen := make(map[string]bool, len(items))
+	unique := items[:0]
+	for _, item := range items {
+		guid := itemGUID(item)
+		if seen[guid] {
+			continue
+		}
+		seen[guid] = true
+		unique = append(unique, item)
+	}
+	return unique
+}
+
+// itemGUID identifies an item within its feed: the <guid> or Atom/JSON Feed
+// id, falling back to the link. Items with neither are identified by a hash
+// of their title, date and description.
+func itemGUID(item RSSItem) string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	if link := strings.TrimSpace(item.Link); link != "" {
+		return link
+	}
+	sum := sha256.Sum256([]byte(item.Title + "\x00" + item.PubDate + "\x00" + item.Description))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// itemAuthor is the item's author, from <author> or dc:creator
+func itemAuthor(item RSSItem) string {
+	if author := strings.TrimSpace(item.Author); author != "" {
+		return author
+	}
+	return strings.TrimSpace(item.DCCreator)
 }
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		contentType string
+		wantTitle   string
+		wantItems   []RSSItem
+		wantedErr   bool
+	}{
+		{
+			name: "rss 2.0",
+			data: `<?xml version="1.0"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/"><channel><title>Blog</title>
+<item><title>First</title><link>https://example.com/1</link><description>one</description><pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+<content:encoded><![CDATA[<p>the whole post</p>]]></content:encoded></item>
+</channel></rss>`,
+			wantTitle: "Blog",
+			wantItems: []RSSItem{
+				{Title: "First", Link: "https://example.com/1", Description: "one", PubDate: "Mon, 02 Jan 2006 15:04:05 -0700", Content: "<p>the whole post</p>"},
+			},
+		},
+		{
+			name: "atom 1.0",
+			data: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Releases</title>
+<link rel="self" href="https://example.com/feed.atom"/>
+<entry><id>tag:1</id><title>v1.0</title>
+<link rel="alternate" type="text/html" href="https://example.com/v1"/>
+<updated>2024-01-03T00:00:00Z</updated><published>2024-01-02T00:00:00Z</published>
+<summary type="html">&lt;p&gt;notes&lt;/p&gt;</summary></entry>
+<entry><id>tag:2</id><title>v2.0</title>
+<link rel="edit" href="https://example.com/edit/v2"/><link href="https://example.com/v2"/>
+<updated>2024-02-01T00:00:00Z</updated>
+<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>body</p></div></content></entry>
+</feed>`,
+			wantTitle: "Releases",
+			wantItems: []RSSItem{
+				{Title: "v1.0", Link: "https://example.com/v1", Description: "<p>notes</p>", PubDate: "2024-01-02T00:00:00Z", Updated: "2024-01-03T00:00:00Z", GUID: "tag:1"},
+				{Title: "v2.0", Link: "https://example.com/v2", Description: `<div xmlns="http://www.w3.org/1999/xhtml"><p>body</p></div>`, Content: `<div xmlns="http://www.w3.org/1999/xhtml"><p>body</p></div>`, Updated: "2024-02-01T00:00:00Z", GUID: "tag:2"},
+			},
+		},
+		{
+			name: "rss 1.0 rdf",
+			data: `<?xml version="1.0" encoding="utf-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="https://example.org/"><title>Papers</title><link>https://example.org/</link><description>preprints</description></channel>
+<item rdf:about="https://example.org/p/1"><title>On Feeds</title><link>https://example.org/p/1</link>
+<description>abstract</description><dc:date>2024-05-06T07:08:09+02:00</dc:date><dc:creator>Ada</dc:creator></item>
+<item rdf:about="https://example.org/p/2"><title>Second</title><link>https://example.org/p/2</link></item>
+</rdf:RDF>`,
+			wantTitle: "Papers",
+			wantItems: []RSSItem{
+				{Title: "On Feeds", Link: "https://example.org/p/1", Description: "abstract", DCDate: "2024-05-06T07:08:09+02:00", GUID: "https://example.org/p/1", Author: "Ada"},
+				{Title: "Second", Link: "https://example.org/p/2", GUID: "https://example.org/p/2"},
+			},
+		},
+		{
+			name: "json feed by content type",
+			data: `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Notes",
+  "items": [
+    {"id": "1", "url": "https://example.com/1", "title": "One", "content_html": "<p>hi</p>",
+     "date_published": "2024-03-01T10:00:00Z", "authors": [{"name": "Ada"}, {"name": "Bob"}]},
+    {"id": "https://example.com/2", "title": "Two", "content_text": "plain", "author": {"name": "Cy"}}
+  ]
+}`,
+			contentType: "application/feed+json; charset=utf-8",
+			wantTitle:   "Notes",
+			wantItems: []RSSItem{
+				{Title: "One", Link: "https://example.com/1", Description: "<p>hi</p>", Content: "<p>hi</p>", PubDate: "2024-03-01T10:00:00Z", GUID: "1", Author: "Ada, Bob"},
+				{Title: "Two", Link: "https://example.com/2", Description: "plain", Content: "plain", GUID: "https://example.com/2", Author: "Cy"},
+			},
+		},
+		{
+			name:        "json feed by sniffing",
+			data:        `  {"version": "https://jsonfeed.org/version/1", "title": "Sniffed", "items": []}`,
+			contentType: "text/plain",
+			wantTitle:   "Sniffed",
+		},
+		{
+			name:        "json that is not a feed",
+			data:        `{"hello": "world"}`,
+			contentType: "application/json",
+			wantedErr:   true,
+		},
+		{
+			name:      "latin-1 declared in xml",
+			data:      "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<rss version=\"2.0\"><channel><title>Caf\xe9</title><item><title>Cr\xe8me br\xfbl\xe9e</title></item></channel></rss>",
+			wantTitle: "Café",
+			wantItems: []RSSItem{{Title: "Crème brûlée"}},
+		},
+		{
+			name:        "windows-1252 from content type overrides declaration",
+			data:        "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<rss version=\"2.0\"><channel><title>\x93Quotes\x94</title></channel></rss>",
+			contentType: "application/rss+xml; charset=windows-1252",
+			wantTitle:   "“Quotes”",
+		},
+		{
+			name:        "unknown charset",
+			data:        "<rss><channel><title>x</title></channel></rss>",
+			contentType: "text/xml; charset=klingon",
+			wantedErr:   true,
+		},
+		{
+			name:      "not xml",
+			data:      "hello",
+			wantedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseFeed([]byte(test.data), test.contentType)
+			if (err != nil) != test.wantedErr {
+				t.Fatalf("Expected error: %v, got: %v", test.wantedErr, err)
+			}
+			if test.wantedErr {
+				return
+			}
+			if got.Channel.Title != test.wantTitle {
+				t.Fatalf("Expected title %q, got %q", test.wantTitle, got.Channel.Title)
+			}
+			if len(got.Channel.Item) != len(test.wantItems) {
+				t.Fatalf("Expected %d items, got %d", len(test.wantItems), len(got.Channel.Item))
+			}
+			for i, want := range test.wantItems {
+				if !reflect.DeepEqual(got.Channel.Item[i], want) {
+					t.Fatalf("Item %d: expected %+v, got %+v", i, want, got.Channel.Item[i])
+				}
+			}
+		})
+	}
+}
+
+func TestItemGUID(t *testing.T) {
+	tests := []struct {
+		name string
+		item RSSItem
+		want string
+	}{
+		{name: "guid", item: RSSItem{GUID: " tag:example.com,2024:1 ", Link: "https://example.com/1"}, want: "tag:example.com,2024:1"},
+		{name: "link fallback", item: RSSItem{Link: "https://example.com/1"}, want: "https://example.com/1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := itemGUID(test.item); got != test.want {
+				t.Fatalf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+
+	// Without guid or link the item is identified by its content
+	a := itemGUID(RSSItem{Title: "One", Description: "same"})
+	b := itemGUID(RSSItem{Title: "Two", Description: "same"})
+	if a == b || !strings.HasPrefix(a, "sha256:") {
+		t.Fatalf("Expected distinct content hashes, got %q and %q", a, b)
+	}
+}
+
+func TestDedupeItems(t *testing.T) {
+	items := []RSSItem{
+		{GUID: "1", Link: "https://example.com/a", Description: ""},
+		{GUID: "2", Link: "https://example.com/a", Description: ""},
+		{GUID: "1", Link: "https://example.com/b"},
+		{Link: "https://example.com/c"},
+		{Link: "https://example.com/c"},
+	}
+
+	got := dedupeItems(items)
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 items, got %d", len(got))
+	}
+	// Items sharing a link or an empty description are still distinct posts
+	if got[0].GUID != "1" || got[1].GUID != "2" || got[2].Link != "https://example.com/c" {
+		t.Fatalf("Unexpected items: %+v", got)
+	}
+}
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+)
+
+const (
+	defaultMinFetchInterval = 15 * time.Minute
+	defaultMaxFetchInterval = 24 * time.Hour
+
+	// defaultFetchInterval is used until a feed has enough dated posts to estimate how often it posts
+	defaultFetchInterval = time.Hour
+
+	// scheduleSampleSize is how many recent posts are used to estimate posting frequency
+	scheduleSampleSize = 20
+)
+
+// scheduleHints are what the publisher tells us about how often to poll
+type scheduleHints struct {
+	TTL          time.Duration // <ttl>
+	UpdatePeriod time.Duration // sy:updatePeriod divided by sy:updateFrequency
+	MaxAge       time.Duration // Cache-Control max-age
+	RetryAfter   time.Duration // Retry-After
+	SkipHours    map[int]bool  // <skipHours>, in GMT
+	SkipDays     map[time.Weekday]bool
+}
+
+// fetchBounds returns the configured min and max fetch intervals, falling back
+// to the defaults when unset or invalid
+func (cfg *Config) fetchBounds() (time.Duration, time.Duration) {
+	minInterval, maxInterval := defaultMinFetchInterval, defaultMaxFetchInterval
+	if cfg == nil {
+		return minInterval, maxInterval
+	}
+
+	if cfg.MinFetchInterval != "" {
+		if d, err := time.ParseDuration(cfg.MinFetchInterval); err == nil && d > 0 {
+			minInterval = d
+		} else {
+			log.Printf("invalid min_fetch_interval %q, using %s", cfg.MinFetchInterval, minInterval)
+		}
+	}
+	if cfg.MaxFetchInterval != "" {
+		if d, err := time.ParseDuration(cfg.MaxFetchInterval); err == nil && d > 0 {
+			maxInterval = d
+		} else {
+			log.Printf("invalid max_fetch_interval %q, using %s", cfg.MaxFetchInterval, maxInterval)
+		}
+	}
+
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+	return minInterval, maxInterval
+}
+
+// scheduleNextFetch works out when the feed should be polled again and stores it.
+// A 304 has no body to read hints from, so the previously computed interval is kept.
+func scheduleNextFetch(ctx context.Context, s *State, feed database.Feed, result *FetchResult) (time.Time, error) {
+	now := time.Now().UTC()
+	minInterval, maxInterval := s.Conf.fetchBounds()
+
+	var hints scheduleHints
+	if result.Feed != nil {
+		hints = feedHints(result.Feed)
+	}
+	hints.MaxAge = result.MaxAge
+
+	var interval time.Duration
+	if result.NotModified && feed.FetchIntervalSeconds > 0 {
+		interval = clampInterval(max(time.Duration(feed.FetchIntervalSeconds)*time.Second, hints.MaxAge), minInterval, maxInterval)
+	} else {
+		dates, err := s.Db.GetRecentPublishDates(ctx, database.GetRecentPublishDatesParams{
+			FeedID: feed.ID,
+			Limit:  scheduleSampleSize,
+		})
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		published := make([]time.Time, 0, len(dates))
+		for _, date := range dates {
+			published = append(published, date.Time)
+		}
+		interval = fetchInterval(postingInterval(published, now), hints, minInterval, maxInterval)
+	}
+	if result.Pushed {
+		// The hub pushes new posts, polling only catches what it misses
+		interval = maxInterval
+	}
+
+	next := nextFetchTime(now, interval, hints)
+	err := s.Db.UpdateFeedSchedule(ctx, database.UpdateFeedScheduleParams{
+		ID:                   feed.ID,
+		NextFetchAt:          sql.NullTime{Time: next, Valid: true},
+		FetchIntervalSeconds: int32(interval / time.Second),
+	})
+	return next, err
+}
+
+// feedHints collects the polling hints published in the feed itself
+func feedHints(feed *RSSFeed) scheduleHints {
+	var hints scheduleHints
+
+	if ttl, err := strconv.Atoi(strings.TrimSpace(feed.Channel.TTL)); err == nil && ttl > 0 {
+		hints.TTL = time.Duration(ttl) * time.Minute
+	}
+
+	hints.UpdatePeriod = updatePeriod(feed.Channel.UpdatePeriod, feed.Channel.UpdateFrequency)
+
+	for _, value := range feed.Channel.SkipHours {
+		if hour, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && hour >= 0 && hour <= 24 {
+			if hints.SkipHours == nil {
+				hints.SkipHours = make(map[int]bool)
+			}
+			// Some feeds number the hours 1-24
+			hints.SkipHours[hour%24] = true
+		}
+	}
+
+	for _, value := range feed.Channel.SkipDays {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(strings.TrimSpace(value), day.String()) {
+				if hints.SkipDays == nil {
+					hints.SkipDays = make(map[time.Weekday]bool)
+				}
+				hints.SkipDays[day] = true
+			}
+		}
+	}
+
+	return hints
+}
+
+// updatePeriod turns sy:updatePeriod and sy:updateFrequency into the time between updates
+func updatePeriod(period, frequency string) time.Duration {
+	var base time.Duration
+	switch strings.ToLower(strings.TrimSpace(period)) {
+	case "hourly":
+		base = time.Hour
+	case "daily":
+		base = 24 * time.Hour
+	case "weekly":
+		base = 7 * 24 * time.Hour
+	case "monthly":
+		base = 30 * 24 * time.Hour
+	case "yearly":
+		base = 365 * 24 * time.Hour
+	default:
+		return 0
+	}
+
+	times, err := strconv.Atoi(strings.TrimSpace(frequency))
+	if err != nil || times < 1 {
+		times = 1
+	}
+	return base / time.Duration(times)
+}
+
+// postingInterval estimates how often a feed posts from its recent publish
+// dates: the median gap between posts, counting the time since the newest one
+// so that a feed that has gone quiet slows down. Zero means too little data.
+func postingInterval(published []time.Time, now time.Time) time.Duration {
+	if len(published) < 2 {
+		return 0
+	}
+
+	dates := append([]time.Time(nil), published...)
+	sort.Slice(dates, func(i, j int) bool { return dates[i].After(dates[j]) })
+
+	gaps := make([]time.Duration, 0, len(dates))
+	if since := now.Sub(dates[0]); since > 0 {
+		gaps = append(gaps, since)
+	}
+	for i := 1; i < len(dates); i++ {
+		gaps = append(gaps, dates[i-1].Sub(dates[i]))
+	}
+
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	return gaps[len(gaps)/2]
+}
+
+// fetchInterval picks how long to wait between polls: half the feed's usual
+// gap between posts, never more often than the publisher asks for, and
+// within the configured bounds
+func fetchInterval(observed time.Duration, hints scheduleHints, minInterval, maxInterval time.Duration) time.Duration {
+	interval := defaultFetchInterval
+	if observed > 0 {
+		interval = observed / 2
+	}
+
+	interval = max(interval, hints.TTL, hints.UpdatePeriod, hints.MaxAge)
+	return clampInterval(interval, minInterval, maxInterval)
+}
+
+func clampInterval(interval, minInterval, maxInterval time.Duration) time.Duration {
+	return min(max(interval, minInterval), maxInterval)
+}
+
+// nextFetchTime adds the interval to now, waits out any Retry-After and moves
+// the result past the hours and days the publisher asked us to skip
+func nextFetchTime(now time.Time, interval time.Duration, hints scheduleHints) time.Time {
+	next := now.Add(interval)
+	if retry := now.Add(hints.RetryAfter); retry.After(next) {
+		next = retry
+	}
+
+	next = next.UTC()
+	// A week of hours covers every combination of skipHours and skipDays
+	for i := 0; i < 7*24 && (hints.SkipHours[next.Hour()] || hints.SkipDays[next.Weekday()]); i++ {
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next
+}
+
+// parseMaxAge reads max-age from a Cache-Control header
+func parseMaxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+// parseRetryAfter reads a Retry-After header, which is either seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
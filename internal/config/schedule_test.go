@@ -0,0 +1,115 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFetchInterval(t *testing.T) {
+	minInterval, maxInterval := 15*time.Minute, 24*time.Hour
+
+	tests := []struct {
+		name     string
+		observed time.Duration
+		hints    scheduleHints
+		want     time.Duration
+	}{
+		{name: "unknown frequency", want: defaultFetchInterval},
+		{name: "half the posting gap", observed: 6 * time.Hour, want: 3 * time.Hour},
+		{name: "busy feed hits the minimum", observed: 5 * time.Minute, want: minInterval},
+		{name: "quiet feed hits the maximum", observed: 30 * 24 * time.Hour, want: maxInterval},
+		{name: "ttl is a floor", observed: time.Hour, hints: scheduleHints{TTL: 2 * time.Hour}, want: 2 * time.Hour},
+		{name: "update period is a floor", observed: time.Hour, hints: scheduleHints{UpdatePeriod: 12 * time.Hour}, want: 12 * time.Hour},
+		{name: "max-age is a floor", observed: time.Hour, hints: scheduleHints{MaxAge: 90 * time.Minute}, want: 90 * time.Minute},
+		{name: "publisher hints are still clamped", hints: scheduleHints{UpdatePeriod: 7 * 24 * time.Hour}, want: maxInterval},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := fetchInterval(test.observed, test.hints, minInterval, maxInterval)
+			if got != test.want {
+				t.Fatalf("Expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPostingInterval(t *testing.T) {
+	now := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+
+	daily := []time.Time{now.Add(-2 * time.Hour), now.Add(-26 * time.Hour), now.Add(-50 * time.Hour), now.Add(-74 * time.Hour)}
+	if got := postingInterval(daily, now); got != 24*time.Hour {
+		t.Fatalf("Expected 24h, got %s", got)
+	}
+
+	// The time since the last post counts, so a feed that stopped posting slows down
+	stale := []time.Time{now.Add(-100 * 24 * time.Hour), now.Add(-101 * 24 * time.Hour), now.Add(-102 * 24 * time.Hour)}
+	if got := postingInterval(stale, now); got != 24*time.Hour {
+		t.Fatalf("Expected 24h, got %s", got)
+	}
+	stale = stale[:2]
+	if got := postingInterval(stale, now); got != 100*24*time.Hour {
+		t.Fatalf("Expected 2400h, got %s", got)
+	}
+
+	if got := postingInterval(daily[:1], now); got != 0 {
+		t.Fatalf("Expected 0 for a single post, got %s", got)
+	}
+}
+
+func TestNextFetchTime(t *testing.T) {
+	// A Saturday
+	now := time.Date(2024, time.June, 8, 10, 30, 0, 0, time.UTC)
+
+	got := nextFetchTime(now, time.Hour, scheduleHints{RetryAfter: 3 * time.Hour})
+	if want := now.Add(3 * time.Hour); !got.Equal(want) {
+		t.Fatalf("Expected Retry-After to win, got %s", got)
+	}
+
+	got = nextFetchTime(now, time.Hour, scheduleHints{SkipHours: map[int]bool{11: true, 12: true}})
+	if want := time.Date(2024, time.June, 8, 13, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+
+	got = nextFetchTime(now, time.Hour, scheduleHints{SkipDays: map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}})
+	if want := time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestFeedHints(t *testing.T) {
+	var feed RSSFeed
+	feed.Channel.TTL = "60"
+	feed.Channel.UpdatePeriod = "daily"
+	feed.Channel.UpdateFrequency = "4"
+	feed.Channel.SkipHours = []string{"0", "24", "bogus"}
+	feed.Channel.SkipDays = []string{"sunday"}
+
+	hints := feedHints(&feed)
+	if hints.TTL != time.Hour {
+		t.Fatalf("Expected ttl 1h, got %s", hints.TTL)
+	}
+	if hints.UpdatePeriod != 6*time.Hour {
+		t.Fatalf("Expected update period 6h, got %s", hints.UpdatePeriod)
+	}
+	if len(hints.SkipHours) != 1 || !hints.SkipHours[0] {
+		t.Fatalf("Expected to skip hour 0, got %v", hints.SkipHours)
+	}
+	if !hints.SkipDays[time.Sunday] {
+		t.Fatalf("Expected to skip Sunday, got %v", hints.SkipDays)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.June, 8, 10, 0, 0, 0, time.UTC)
+
+	if got := parseRetryAfter("120", now); got != 2*time.Minute {
+		t.Fatalf("Expected 2m, got %s", got)
+	}
+	if got := parseRetryAfter("Sat, 08 Jun 2024 11:00:00 GMT", now); got != time.Hour {
+		t.Fatalf("Expected 1h, got %s", got)
+	}
+	if got := parseMaxAge("public, max-age=1800"); got != 30*time.Minute {
+		t.Fatalf("Expected 30m, got %s", got)
+	}
+}
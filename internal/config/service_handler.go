@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
 	"github.com/eniolaomotee/BlogGator-Go/service"
 )
 
@@ -59,7 +60,7 @@ func ServiceManagerHandler(s *State, cmd Command, user database.User) error {
 
 	switch actions {
 	case "start":
-		return handleServiceStart(manager, pidFile, logDir)
+		return handleServiceStart(manager, pidFile, logDir, s.Conf.ServiceMetricsAddr)
 	case "stop":
 		return handleServiceStop(pidFile)
 	case "restart":
@@ -67,7 +68,7 @@ func ServiceManagerHandler(s *State, cmd Command, user database.User) error {
 			fmt.Printf("warning: %v", err)
 		}
 		time.Sleep(2 * time.Second)
-		return handleServiceStart(manager, pidFile, logDir)
+		return handleServiceStart(manager, pidFile, logDir, s.Conf.ServiceMetricsAddr)
 	case "status":
 		return handleServiceStatus(pidFile, logDir)
 	case "logs":
@@ -100,7 +101,7 @@ func printServiceHelp() {
 	fmt.Println("  gator service logs 100          - Show last 100 log lines")
 }
 
-func handleServiceStart(manager *service.Manager, pidFile, logDir string) error {
+func handleServiceStart(manager *service.Manager, pidFile, logDir, metricsAddr string) error {
 	// Check if running
 	if isServiceRunning(pidFile) {
 		fmt.Println("service is already running")
@@ -117,6 +118,11 @@ func handleServiceStart(manager *service.Manager, pidFile, logDir string) error
 		WorkingDir: "",
 	}, manager)
 
+	// Restart counts live in the daemon, so it serves its own metrics
+	if metricsAddr != "" {
+		metrics.Serve(metricsAddr)
+	}
+
 	// start daemon
 	if err := deamon.Start(); err != nil {
 		return fmt.Errorf("couldn't start daemon %s", err)
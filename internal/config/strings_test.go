@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		input string
+		n     int
+		want  string
+	}{
+		{input: "short", n: 10, want: "short"},
+		{input: "exactly", n: 7, want: "exactly"},
+		{input: "abcdef", n: 3, want: "abc"},
+		{input: "café", n: 4, want: "caf"}, // é is two bytes
+		{input: "日本語", n: 5, want: "日"},
+		{input: "日本語", n: 0, want: ""},
+	}
+
+	for _, test := range tests {
+		if got := truncateUTF8(test.input, test.n); got != test.want {
+			t.Fatalf("Expected %q for %q cut to %d, got %q", test.want, test.input, test.n, got)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/sanitize"
+	"github.com/google/uuid"
 	"github.com/pkg/browser"
 	"strings"
 )
@@ -27,16 +29,24 @@ type PostItem struct {
 	description string
 	feedName    string
 	PublishedAt string
+	updated     bool
 }
 
 func (i PostItem) FilterValue() string { return i.title }
 func (i PostItem) Title() string       { return i.title }
-func (i PostItem) Description() string { return fmt.Sprintf("%s . %s", i.feedName, i.PublishedAt) }
+func (i PostItem) Description() string {
+	if i.updated {
+		return fmt.Sprintf("%s . %s . ✎ updated", i.feedName, i.PublishedAt)
+	}
+	return fmt.Sprintf("%s . %s", i.feedName, i.PublishedAt)
+}
 
 // TUI model
 type tuiModel struct {
-	list  list.Model
-	posts []database.GetPostsForUserSortedRow
+	list       list.Model
+	posts      []database.GetPostsForUserSortedRow
+	enclosures map[uuid.UUID][]database.Enclosure
+	revisions  map[uuid.UUID]database.PostRevision // latest revision of updated posts
 	//	selected int
 	viewing  bool
 	quitting bool
@@ -122,19 +132,40 @@ func (m tuiModel) viewPost() string {
 	metadata := fmt.Sprintf("📡 %s  •  📅 %s",
 		post.FeedName,
 		post.PublishedAt.Time.Format("Mon Jan 2, 2006 3:04 PM"))
+	if post.ReadingTimeMinutes > 0 {
+		metadata += fmt.Sprintf("  •  ⏱ %d min read", post.ReadingTimeMinutes)
+	}
+	if post.RevisedAt.Valid {
+		metadata += "  •  ✎ updated " + post.RevisedAt.Time.Format("Jan 2, 2006")
+		if revision, ok := m.revisions[post.ID]; ok && revision.Changed != "" {
+			metadata += " (" + strings.ReplaceAll(revision.Changed, ",", ", ") + " changed)"
+		}
+	}
 	s.WriteString(lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#888888")).
 		MarginLeft(2).
 		Render(metadata))
 	s.WriteString("\n\n")
 
-	// Description
-	if post.Description.Valid && post.Description.String != "" {
-		wrapped := wordWrap(post.Description.String, 80)
+	// Full text when we have it, the description otherwise
+	if body := sanitize.Text(postBody(post.ArticleHtml, post.Content, post.Description)); body != "" {
+		wrapped := wordWrap(body, 80)
 		s.WriteString(descStyle.Render(wrapped))
 		s.WriteString("\n\n")
 	}
 
+	// Podcast episodes
+	for _, enclosure := range m.enclosures[post.ID] {
+		s.WriteString(lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#888888")).
+			MarginLeft(2).
+			Render("🎧 " + describeEnclosure(enclosure)))
+		s.WriteString("\n")
+	}
+	if len(m.enclosures[post.ID]) > 0 {
+		s.WriteString("\n")
+	}
+
 	// URL
 	s.WriteString(lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#7D56F4")).
@@ -148,33 +179,50 @@ func (m tuiModel) viewPost() string {
 	return s.String()
 }
 
-// wordWrap wraps text to a specified width
+// wordWrap wraps text to a specified width. Line breaks in the text, such as
+// paragraph gaps, are kept and only lines that are too long are wrapped,
+// keeping their indentation. Lines inside ``` code blocks are left alone.
 func wordWrap(text string, width int) string {
-	var result strings.Builder
-	var line strings.Builder
-	words := strings.Fields(text)
-
-	for _, word := range words {
-		if line.Len()+len(word)+1 > width {
-			result.WriteString(line.String())
-			result.WriteString("\n")
-			line.Reset()
+	lines := strings.Split(text, "\n")
+	wrapped := make([]string, 0, len(lines))
+
+	inCode := false
+	for _, paragraph := range lines {
+		// Fences may sit inside a quote, as "> ```"
+		if strings.HasPrefix(strings.TrimLeft(paragraph, "> "), "```") {
+			inCode = !inCode
+			wrapped = append(wrapped, paragraph)
+			continue
 		}
-		if line.Len() > 0 {
-			line.WriteString(" ")
+		if inCode || len(paragraph) <= width {
+			wrapped = append(wrapped, paragraph)
+			continue
 		}
-		line.WriteString(word)
-	}
 
-	if line.Len() > 0 {
-		result.WriteString(line.String())
+		indent := paragraph[:len(paragraph)-len(strings.TrimLeft(paragraph, " \t"))]
+		var line strings.Builder
+		for _, word := range strings.Fields(paragraph) {
+			if line.Len() > 0 && line.Len()+len(word)+1 > width {
+				wrapped = append(wrapped, line.String())
+				line.Reset()
+			}
+			if line.Len() > 0 {
+				line.WriteString(" ")
+			} else {
+				line.WriteString(indent)
+			}
+			line.WriteString(word)
+		}
+		if line.Len() > 0 {
+			wrapped = append(wrapped, line.String())
+		}
 	}
 
-	return result.String()
+	return strings.Join(wrapped, "\n")
 }
 
 // NewTUI creates a new TUI model
-func NewTUI(posts []database.GetPostsForUserSortedRow) tuiModel {
+func NewTUI(posts []database.GetPostsForUserSortedRow, enclosures map[uuid.UUID][]database.Enclosure, revisions map[uuid.UUID]database.PostRevision) tuiModel {
 	items := make([]list.Item, len(posts))
 	for i, post := range posts {
 		items[i] = PostItem{
@@ -183,6 +231,7 @@ func NewTUI(posts []database.GetPostsForUserSortedRow) tuiModel {
 			description: post.Description.String,
 			feedName:    post.FeedName,
 			PublishedAt: post.PublishedAt.Time.Format("Jan 2, 2006"),
+			updated:     post.RevisedAt.Valid,
 		}
 	}
 
@@ -197,7 +246,9 @@ func NewTUI(posts []database.GetPostsForUserSortedRow) tuiModel {
 	l.Styles.Title = titleStyle
 
 	return tuiModel{
-		list:  l,
-		posts: posts,
+		list:       l,
+		posts:      posts,
+		enclosures: enclosures,
+		revisions:  revisions,
 	}
 }
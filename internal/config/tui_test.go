@@ -0,0 +1,36 @@
+package config
+
+import "testing"
+
+func TestWordWrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  string
+	}{
+		{name: "short lines kept", text: "one two\n\nthree", width: 10, want: "one two\n\nthree"},
+		{name: "long line wrapped", text: "one two three four", width: 9, want: "one two\nthree\nfour"},
+		{name: "indentation kept", text: "    one two three four", width: 13, want: "    one two\n    three\n    four"},
+		{
+			name:  "code block untouched",
+			text:  "```\nfunc main() { fmt.Println(\"a long line\") }\n    indented code\n```\nafter the code block",
+			width: 10,
+			want:  "```\nfunc main() { fmt.Println(\"a long line\") }\n    indented code\n```\nafter the\ncode block",
+		},
+		{
+			name:  "quoted code block untouched",
+			text:  "> ```\n> x := []int{1, 2, 3, 4, 5}\n> ```",
+			width: 10,
+			want:  "> ```\n> x := []int{1, 2, 3, 4, 5}\n> ```",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := wordWrap(test.text, test.width); got != test.want {
+				t.Fatalf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
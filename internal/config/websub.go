@@ -0,0 +1,172 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/api"
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
+	"github.com/eniolaomotee/BlogGator-Go/internal/websub"
+	"github.com/google/uuid"
+)
+
+const (
+	// webSubRenewWithin is how long before a lease expires it is renewed
+	webSubRenewWithin = 24 * time.Hour
+
+	// webSubRetryAfter is how long a pending, denied or failed subscription
+	// waits before it is requested again
+	webSubRetryAfter = 6 * time.Hour
+)
+
+// feedHub returns the WebSub hub and topic of a feed. Link headers win over
+// the feed's own links, as the spec asks.
+func feedHub(header http.Header, feed *RSSFeed) (hub, topic string) {
+	hub, topic = websub.DiscoverLinks(header)
+	for _, link := range feed.Channel.AtomLinks {
+		href := strings.TrimSpace(link.Href)
+		if hub == "" && link.Rel == "hub" {
+			hub = href
+		}
+		if topic == "" && link.Rel == "self" {
+			topic = href
+		}
+	}
+	return hub, topic
+}
+
+// webSubCallback is where the hub sends the feed's updates, empty when WebSub is off
+func (cfg *Config) webSubCallback(feedID uuid.UUID) string {
+	if cfg == nil || cfg.WebSubCallbackURL == "" {
+		return ""
+	}
+	return strings.TrimRight(cfg.WebSubCallbackURL, "/") + "/api/websub/" + feedID.String()
+}
+
+// ensureWebSub subscribes to the feed's hub unless we already are, and
+// reports whether the hub is currently pushing updates
+func ensureWebSub(ctx context.Context, s *State, feed database.Feed, result *FetchResult) (bool, error) {
+	if s.Conf.webSubCallback(feed.ID) == "" {
+		return false, nil
+	}
+
+	sub, err := s.Db.GetWebSubSubscription(ctx, feed.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	found := err == nil
+
+	if result.Hub == "" {
+		// The feed stopped advertising a hub; pushes stop when the lease runs out
+		return found && sub.State == "active", nil
+	}
+	if found && sub.HubUrl == result.Hub && sub.TopicUrl == result.Topic {
+		// Renewals and retries are handled by renewWebSubSubscriptions
+		return sub.State == "active", nil
+	}
+
+	secret, err := websub.NewSecret()
+	if err != nil {
+		return false, err
+	}
+	return false, subscribeWebSub(ctx, s, feed.ID, result.Hub, result.Topic, secret)
+}
+
+// subscribeWebSub records the subscription as pending and asks the hub for it.
+// The hub confirms through the callback, served by `gator serve`.
+func subscribeWebSub(ctx context.Context, s *State, feedID uuid.UUID, hub, topic, secret string) error {
+	sealed, err := api.SealWebSubSecret(s.Secrets, feedID, secret)
+	if err != nil {
+		return fmt.Errorf("couldn't encrypt websub secret: %w", err)
+	}
+	err = s.Db.UpsertWebSubSubscription(ctx, database.UpsertWebSubSubscriptionParams{
+		FeedID:   feedID,
+		HubUrl:   hub,
+		TopicUrl: topic,
+		Secret:   sealed,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Through the fetcher, so the hub URL gets the same address checks as feeds
+	err = websub.Subscribe(ctx, s.Fetcher.Client(nil), hub, topic, s.Conf.webSubCallback(feedID), secret, websub.DefaultLease)
+	if err != nil {
+		stateErr := s.Db.SetWebSubSubscriptionState(ctx, database.SetWebSubSubscriptionStateParams{
+			FeedID:    feedID,
+			State:     "failed",
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if stateErr != nil {
+			log.Printf("couldn't record failed subscription to %s: %s", hub, stateErr)
+		}
+		return fmt.Errorf("couldn't subscribe to hub %s: %w", hub, err)
+	}
+
+	log.Printf("Subscribed to %s at hub %s, waiting for the hub to verify", topic, hub)
+	return nil
+}
+
+// renewWebSubSubscriptions renews leases that are about to expire and retries
+// subscriptions the hub never confirmed, refused or that failed
+func renewWebSubSubscriptions(ctx context.Context, s *State) {
+	if s.Conf == nil || s.Conf.WebSubCallbackURL == "" {
+		return
+	}
+
+	subs, err := s.Db.GetWebSubSubscriptionsToRenew(ctx, database.GetWebSubSubscriptionsToRenewParams{
+		RenewWithinSeconds: int32(webSubRenewWithin / time.Second),
+		RetryAfterSeconds:  int32(webSubRetryAfter / time.Second),
+	})
+	if err != nil {
+		log.Printf("error loading websub subscriptions to renew: %s", err)
+		return
+	}
+
+	for _, sub := range subs {
+		secret, err := api.OpenWebSubSecret(s.Secrets, sub.FeedID, sub.Secret)
+		if err != nil {
+			log.Printf("error renewing websub subscription: %s", err)
+			continue
+		}
+		if err := subscribeWebSub(ctx, s, sub.FeedID, sub.HubUrl, sub.TopicUrl, secret); err != nil {
+			log.Printf("error renewing websub subscription: %s", err)
+		}
+	}
+}
+
+// ingestPush stores the items of a document the hub pushed for a feed.
+// Pushes for disabled feeds are dropped; the subscription lapses with its lease.
+func ingestPush(ctx context.Context, s *State, feedID uuid.UUID, body []byte, contentType string) error {
+	feed, err := s.Db.GetFeedByID(ctx, feedID)
+	if err != nil {
+		return err
+	}
+	if feed.DisabledAt.Valid {
+		log.Printf("Feed %s is disabled, ignoring push from its hub", feed.Name)
+		return nil
+	}
+
+	rss, err := parseFeed(body, contentType)
+	if err != nil {
+		return err
+	}
+	prepareFeed(rss)
+
+	stored, err := storePosts(ctx, s, feed.ID, rss.Channel.Item, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	metrics.ObservePosts(metrics.SourcePush, stored.New, stored.Updated)
+
+	log.Printf("Feed %s pushed by its hub: %d new, %d updated, %d unchanged of %d items",
+		feed.Name, stored.New, stored.Updated, stored.Unchanged, len(rss.Channel.Item))
+	return nil
+}
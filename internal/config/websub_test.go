@@ -0,0 +1,81 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFeedHub(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		linkHeader  string
+		wantHub     string
+		wantTopic   string
+		wantChannel string
+	}{
+		{
+			name: "rss with atom links",
+			data: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>Blog</title>
+<atom:link rel="hub" href="https://hub.example.com/"/>
+<link>https://blog.example.com/</link>
+<atom:link rel="self" href="https://blog.example.com/feed.xml"/>
+</channel></rss>`,
+			wantHub:     "https://hub.example.com/",
+			wantTopic:   "https://blog.example.com/feed.xml",
+			wantChannel: "https://blog.example.com/",
+		},
+		{
+			name: "atom",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+<link rel="alternate" href="https://blog.example.com/"/>
+<link rel="hub" href="https://hub.example.com/"/>
+<link rel="self" href="https://blog.example.com/feed.atom"/>
+</feed>`,
+			wantHub:     "https://hub.example.com/",
+			wantTopic:   "https://blog.example.com/feed.atom",
+			wantChannel: "https://blog.example.com/",
+		},
+		{
+			name:        "json feed",
+			data:        `{"version": "https://jsonfeed.org/version/1.1", "title": "Blog", "home_page_url": "https://blog.example.com/", "feed_url": "https://blog.example.com/feed.json", "hubs": [{"type": "rssCloud", "url": "https://cloud.example.com/"}, {"type": "WebSub", "url": "https://hub.example.com/"}], "items": []}`,
+			wantHub:     "https://hub.example.com/",
+			wantTopic:   "https://blog.example.com/feed.json",
+			wantChannel: "https://blog.example.com/",
+		},
+		{
+			name: "link header wins",
+			data: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>Blog</title>
+<atom:link rel="hub" href="https://old-hub.example.com/"/>
+</channel></rss>`,
+			linkHeader: `<https://hub.example.com/>; rel="hub"`,
+			wantHub:    "https://hub.example.com/",
+		},
+		{
+			name:        "no hub",
+			data:        `<rss version="2.0"><channel><title>Blog</title><link>https://blog.example.com/</link></channel></rss>`,
+			wantChannel: "https://blog.example.com/",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			feed, err := parseFeed([]byte(test.data), "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			header := http.Header{}
+			if test.linkHeader != "" {
+				header.Set("Link", test.linkHeader)
+			}
+
+			hub, topic := feedHub(header, feed)
+			if hub != test.wantHub || topic != test.wantTopic {
+				t.Fatalf("Expected hub %q and topic %q, got %q and %q", test.wantHub, test.wantTopic, hub, topic)
+			}
+			if feed.Channel.Link != test.wantChannel {
+				t.Fatalf("Expected channel link %q, got %q", test.wantChannel, feed.Channel.Link)
+			}
+		})
+	}
+}
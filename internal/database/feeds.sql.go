@@ -7,11 +7,132 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const claimFeed = `-- name: ClaimFeed :one
+UPDATE feeds
+SET
+    lease_owner = $1::text,
+    lease_expires_at = NOW() + make_interval(secs => $2::int)
+WHERE id = $3
+  AND (lease_expires_at IS NULL OR lease_expires_at <= NOW())
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_status, last_success_at, disabled_at, lease_owner, lease_expires_at
+`
+
+type ClaimFeedParams struct {
+	Owner        string
+	LeaseSeconds int32
+	ID           uuid.UUID
+}
+
+// Leases one feed for an on-demand refresh, unless an instance is fetching it
+func (q *Queries) ClaimFeed(ctx context.Context, arg ClaimFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimFeed, arg.Owner, arg.LeaseSeconds, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastStatus,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+	)
+	return i, err
+}
+
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET
+    lease_owner = $1::text,
+    lease_expires_at = NOW() + make_interval(secs => $2::int)
+WHERE id IN (
+    SELECT due.id
+    FROM feeds due
+    WHERE due.disabled_at IS NULL
+      AND (due.next_fetch_at IS NULL OR due.next_fetch_at <= NOW())
+      AND (due.lease_expires_at IS NULL OR due.lease_expires_at <= NOW())
+      AND due.id <> ALL($3::uuid[])
+    ORDER BY due.next_fetch_at ASC NULLS FIRST, due.last_fetched_at ASC NULLS FIRST
+    LIMIT $4::int
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_status, last_success_at, disabled_at, lease_owner, lease_expires_at
+`
+
+type ClaimFeedsToFetchParams struct {
+	Owner        string
+	LeaseSeconds int32
+	Exclude      []uuid.UUID
+	BatchSize    int32
+}
+
+// Leases the next due feeds to one aggregator instance. SKIP LOCKED lets
+// instances claim at the same time without waiting on each other, and an
+// expired lease is up for grabs again. Feeds in exclude aren't claimed.
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch,
+		arg.Owner,
+		arg.LeaseSeconds,
+		pq.Array(arg.Exclude),
+		arg.BatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.LastFetchedAt,
+			&i.UserID,
+			&i.Etag,
+			&i.LastModified,
+			&i.NextFetchAt,
+			&i.FetchIntervalSeconds,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastStatus,
+			&i.LastSuccessAt,
+			&i.DisabledAt,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds(id, created_at, updated_at, name, url, user_id)
 VALUES (
@@ -21,7 +142,7 @@ VALUES (
     $4,
     $5,
     $6
-) RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id
+) RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_status, last_success_at, disabled_at, lease_owner, lease_expires_at
 `
 
 type CreateFeedParams struct {
@@ -51,12 +172,91 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastStatus,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+	)
+	return i, err
+}
+
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const disableFeed = `-- name: DisableFeed :exec
+UPDATE feeds
+SET
+    disabled_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) DisableFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableFeed, id)
+	return err
+}
+
+const enableFeed = `-- name: EnableFeed :exec
+UPDATE feeds
+SET
+    disabled_at = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) EnableFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, enableFeed, id)
+	return err
+}
+
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_status, last_success_at, disabled_at, lease_owner, lease_expires_at FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastStatus,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
 	)
 	return i, err
 }
 
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id 
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_status, last_success_at, disabled_at, lease_owner, lease_expires_at 
 FROM feeds
 WHERE url = $1
 `
@@ -72,16 +272,111 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.LastFetchedAt,
 		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastStatus,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
 	)
 	return i, err
 }
 
-const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id FROM feeds
+const getFeedByURLForUser = `-- name: GetFeedByURLForUser :one
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.last_fetched_at, feeds.user_id, feeds.etag, feeds.last_modified, feeds.next_fetch_at, feeds.fetch_interval_seconds, feeds.consecutive_failures, feeds.last_error, feeds.last_status, feeds.last_success_at, feeds.disabled_at, feeds.lease_owner, feeds.lease_expires_at
+FROM feeds
+WHERE feeds.url = $1
+  AND (feeds.user_id = $2
+   OR feeds.id IN (SELECT feed_id FROM feed_follows WHERE feed_follows.user_id = $2))
 `
 
-func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getFeeds)
+type GetFeedByURLForUserParams struct {
+	Url    string
+	UserID uuid.UUID
+}
+
+// A feed the user added or follows, by URL
+func (q *Queries) GetFeedByURLForUser(ctx context.Context, arg GetFeedByURLForUserParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURLForUser, arg.Url, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastStatus,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+	)
+	return i, err
+}
+
+const getFeedForUser = `-- name: GetFeedForUser :one
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.last_fetched_at, feeds.user_id, feeds.etag, feeds.last_modified, feeds.next_fetch_at, feeds.fetch_interval_seconds, feeds.consecutive_failures, feeds.last_error, feeds.last_status, feeds.last_success_at, feeds.disabled_at, feeds.lease_owner, feeds.lease_expires_at
+FROM feeds
+WHERE feeds.id = $1
+  AND (feeds.user_id = $2
+   OR feeds.id IN (SELECT feed_id FROM feed_follows WHERE feed_follows.user_id = $2))
+`
+
+type GetFeedForUserParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// A feed the user added or follows
+func (q *Queries) GetFeedForUser(ctx context.Context, arg GetFeedForUserParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedForUser, arg.ID, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastStatus,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+	)
+	return i, err
+}
+
+const getFeedHealthForUser = `-- name: GetFeedHealthForUser :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.last_fetched_at, feeds.user_id, feeds.etag, feeds.last_modified, feeds.next_fetch_at, feeds.fetch_interval_seconds, feeds.consecutive_failures, feeds.last_error, feeds.last_status, feeds.last_success_at, feeds.disabled_at, feeds.lease_owner, feeds.lease_expires_at
+FROM feeds
+WHERE feeds.user_id = $1
+   OR feeds.id IN (SELECT feed_id FROM feed_follows WHERE feed_follows.user_id = $1)
+ORDER BY feeds.name
+`
+
+func (q *Queries) GetFeedHealthForUser(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedHealthForUser, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -97,6 +392,17 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.LastFetchedAt,
 			&i.UserID,
+			&i.Etag,
+			&i.LastModified,
+			&i.NextFetchAt,
+			&i.FetchIntervalSeconds,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastStatus,
+			&i.LastSuccessAt,
+			&i.DisabledAt,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
 		); err != nil {
 			return nil, err
 		}
@@ -111,15 +417,12 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :many
-SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.last_fetched_at, feeds.user_id
-FROM feeds 
-ORDER BY feeds.last_fetched_at ASC NULLS FIRST 
-LIMIT $1
+const getFeeds = `-- name: GetFeeds :many
+SELECT id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_status, last_success_at, disabled_at, lease_owner, lease_expires_at FROM feeds
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context, limit int32) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getNextFeedToFetch, limit)
+func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeeds)
 	if err != nil {
 		return nil, err
 	}
@@ -135,6 +438,17 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context, limit int32) ([]Feed,
 			&i.Url,
 			&i.LastFetchedAt,
 			&i.UserID,
+			&i.Etag,
+			&i.LastModified,
+			&i.NextFetchAt,
+			&i.FetchIntervalSeconds,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastStatus,
+			&i.LastSuccessAt,
+			&i.DisabledAt,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
 		); err != nil {
 			return nil, err
 		}
@@ -175,3 +489,166 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
 	return err
 }
+
+const recordFeedFailure = `-- name: RecordFeedFailure :one
+UPDATE feeds
+SET
+    consecutive_failures = consecutive_failures + 1,
+    last_error = $2,
+    last_status = $3,
+    next_fetch_at = $4,
+    disabled_at = CASE WHEN consecutive_failures + 1 >= $5::int THEN NOW() ELSE disabled_at END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, last_fetched_at, user_id, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_status, last_success_at, disabled_at, lease_owner, lease_expires_at
+`
+
+type RecordFeedFailureParams struct {
+	ID          uuid.UUID
+	LastError   sql.NullString
+	LastStatus  sql.NullInt32
+	NextFetchAt sql.NullTime
+	Column5     int32
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure,
+		arg.ID,
+		arg.LastError,
+		arg.LastStatus,
+		arg.NextFetchAt,
+		arg.Column5,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.LastFetchedAt,
+		&i.UserID,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastStatus,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+	)
+	return i, err
+}
+
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET
+    consecutive_failures = 0,
+    last_error = NULL,
+    last_status = $2,
+    last_success_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type RecordFeedSuccessParams struct {
+	ID         uuid.UUID
+	LastStatus sql.NullInt32
+}
+
+func (q *Queries) RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess, arg.ID, arg.LastStatus)
+	return err
+}
+
+const releaseFeedLease = `-- name: ReleaseFeedLease :exec
+UPDATE feeds
+SET
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE id = $1 AND lease_owner = $2::text
+`
+
+type ReleaseFeedLeaseParams struct {
+	ID    uuid.UUID
+	Owner string
+}
+
+func (q *Queries) ReleaseFeedLease(ctx context.Context, arg ReleaseFeedLeaseParams) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedLease, arg.ID, arg.Owner)
+	return err
+}
+
+const releaseFeedLeases = `-- name: ReleaseFeedLeases :exec
+UPDATE feeds
+SET
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE lease_owner = $1::text
+`
+
+func (q *Queries) ReleaseFeedLeases(ctx context.Context, owner string) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedLeases, owner)
+	return err
+}
+
+const updateFeedCacheHeaders = `-- name: UpdateFeedCacheHeaders :exec
+UPDATE feeds
+SET
+    etag = $2,
+    last_modified = $3,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedCacheHeadersParams struct {
+	ID           uuid.UUID
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) UpdateFeedCacheHeaders(ctx context.Context, arg UpdateFeedCacheHeadersParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCacheHeaders, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const updateFeedSchedule = `-- name: UpdateFeedSchedule :exec
+UPDATE feeds
+SET
+    next_fetch_at = $2,
+    fetch_interval_seconds = $3,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedScheduleParams struct {
+	ID                   uuid.UUID
+	NextFetchAt          sql.NullTime
+	FetchIntervalSeconds int32
+}
+
+func (q *Queries) UpdateFeedSchedule(ctx context.Context, arg UpdateFeedScheduleParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedSchedule, arg.ID, arg.NextFetchAt, arg.FetchIntervalSeconds)
+	return err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET
+    url = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedURLParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.ID, arg.Url)
+	return err
+}
@@ -22,14 +22,65 @@ type ApiKey struct {
 	ExpiresAt  sql.NullTime
 }
 
+type Enclosure struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	MimeType        string
+	LengthBytes     sql.NullInt64
+	DurationSeconds sql.NullInt32
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	LastFetchedAt sql.NullTime
-	UserID        uuid.UUID
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Name                 string
+	Url                  string
+	LastFetchedAt        sql.NullTime
+	UserID               uuid.UUID
+	Etag                 sql.NullString
+	LastModified         sql.NullString
+	NextFetchAt          sql.NullTime
+	FetchIntervalSeconds int32
+	ConsecutiveFailures  int32
+	LastError            sql.NullString
+	LastStatus           sql.NullInt32
+	LastSuccessAt        sql.NullTime
+	DisabledAt           sql.NullTime
+	LeaseOwner           sql.NullString
+	LeaseExpiresAt       sql.NullTime
+}
+
+type FeedCredential struct {
+	FeedID    uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Secret    []byte
+}
+
+type FeedEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+	Event     string
+	OldUrl    sql.NullString
+	NewUrl    sql.NullString
+	Detail    sql.NullString
+}
+
+type FeedFetch struct {
+	ID         uuid.UUID
+	FeedID     uuid.UUID
+	StartedAt  time.Time
+	DurationMs int32
+	Status     sql.NullInt32
+	Bytes      int64
+	ItemsSeen  int32
+	NewPosts   int32
+	Error      sql.NullString
 }
 
 type FeedFollow struct {
@@ -41,14 +92,34 @@ type FeedFollow struct {
 }
 
 type Post struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.UUID
+	PublishedAtInferred bool
+	Content             sql.NullString
+	ArticleHtml         sql.NullString
+	WordCount           int32
+	ReadingTimeMinutes  int32
+	Guid                string
+	Author              sql.NullString
+	ContentHash         string
+	RevisedAt           sql.NullTime
+}
+
+type PostRevision struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	PostID      uuid.UUID
 	Title       string
-	Url         string
 	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	Content     sql.NullString
+	ContentHash string
+	Changed     string
 }
 
 type User struct {
@@ -58,3 +129,20 @@ type User struct {
 	Name         string
 	PasswordHash string
 }
+
+type UserRefresh struct {
+	UserID      uuid.UUID
+	RefreshedAt time.Time
+}
+
+type WebsubSubscription struct {
+	FeedID         uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	HubUrl         string
+	TopicUrl       string
+	Secret         []byte
+	State          string
+	LeaseExpiresAt sql.NullTime
+	LastError      sql.NullString
+}
@@ -11,36 +11,124 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id,created_at, updated_at,title, url, description, published_at, feed_id)
-VALUES($1,$2,$3,$4,$5,$6,$7,$8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+const adoptLegacyPostGUIDs = `-- name: AdoptLegacyPostGUIDs :execrows
+UPDATE posts
+SET guid = i.guid, updated_at = NOW()
+FROM unnest($1::text[], $2::text[]) AS i(guid, url)
+WHERE posts.feed_id = $3::uuid
+  AND posts.guid = posts.url
+  AND posts.url = i.url
+  AND i.guid <> i.url
+  AND NOT EXISTS (SELECT 1 FROM posts known WHERE known.feed_id = $3::uuid AND known.guid = i.guid)
 `
 
-type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+type AdoptLegacyPostGUIDsParams struct {
+	Guids  []string
+	Urls   []string
+	FeedID uuid.UUID
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.ID,
+// Posts stored before GUIDs were tracked have their link as GUID. Give them
+// the item's real GUID, so they are recognised rather than stored again.
+func (q *Queries) AdoptLegacyPostGUIDs(ctx context.Context, arg AdoptLegacyPostGUIDsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, adoptLegacyPostGUIDs, pq.Array(arg.Guids), pq.Array(arg.Urls), arg.FeedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createPosts = `-- name: CreatePosts :many
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_inferred, content, word_count, reading_time_minutes, guid, author, content_hash)
+SELECT
+    i.id, $1::timestamp, $1::timestamp, i.title, i.url, i.description, i.published_at,
+    $2::uuid, i.published_at_inferred, NULLIF(i.content, ''), i.word_count, i.reading_time_minutes,
+    i.guid, NULLIF(i.author, ''), i.content_hash
+FROM unnest(
+    $3::uuid[], $4::text[], $5::text[], $6::text[], $7::timestamp[],
+    $8::boolean[], $9::text[], $10::integer[], $11::integer[],
+    $12::text[], $13::text[], $14::text[]
+) AS i(id, title, url, description, published_at, published_at_inferred, content, word_count, reading_time_minutes, guid, author, content_hash)
+ON CONFLICT (feed_id, guid) DO NOTHING
+RETURNING id, guid
+`
+
+type CreatePostsParams struct {
+	CreatedAt            time.Time
+	FeedID               uuid.UUID
+	Ids                  []uuid.UUID
+	Titles               []string
+	Urls                 []string
+	Descriptions         []string
+	PublishedAts         []time.Time
+	PublishedAtInferreds []bool
+	Contents             []string
+	WordCounts           []int32
+	ReadingTimes         []int32
+	Guids                []string
+	Authors              []string
+	ContentHashes        []string
+}
+
+type CreatePostsRow struct {
+	ID   uuid.UUID
+	Guid string
+}
+
+func (q *Queries) CreatePosts(ctx context.Context, arg CreatePostsParams) ([]CreatePostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, createPosts,
 		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
 		arg.FeedID,
+		pq.Array(arg.Ids),
+		pq.Array(arg.Titles),
+		pq.Array(arg.Urls),
+		pq.Array(arg.Descriptions),
+		pq.Array(arg.PublishedAts),
+		pq.Array(arg.PublishedAtInferreds),
+		pq.Array(arg.Contents),
+		pq.Array(arg.WordCounts),
+		pq.Array(arg.ReadingTimes),
+		pq.Array(arg.Guids),
+		pq.Array(arg.Authors),
+		pq.Array(arg.ContentHashes),
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CreatePostsRow
+	for rows.Next() {
+		var i CreatePostsRow
+		if err := rows.Scan(&i.ID, &i.Guid); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostForUser = `-- name: GetPostForUser :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.published_at_inferred, posts.content, posts.article_html, posts.word_count, posts.reading_time_minutes, posts.guid, posts.author, posts.content_hash, posts.revised_at FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+WHERE posts.id = $1 AND feed_follows.user_id = $2
+`
+
+type GetPostForUserParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetPostForUser(ctx context.Context, arg GetPostForUserParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUser, arg.ID, arg.UserID)
 	var i Post
 	err := row.Scan(
 		&i.ID,
@@ -51,12 +139,126 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.PublishedAtInferred,
+		&i.Content,
+		&i.ArticleHtml,
+		&i.WordCount,
+		&i.ReadingTimeMinutes,
+		&i.Guid,
+		&i.Author,
+		&i.ContentHash,
+		&i.RevisedAt,
 	)
 	return i, err
 }
 
+const getPostsByFeedGUIDs = `-- name: GetPostsByFeedGUIDs :many
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_inferred, content, article_html, word_count, reading_time_minutes, guid, author, content_hash, revised_at FROM posts
+WHERE feed_id = $1 AND guid = ANY($2::text[])
+`
+
+type GetPostsByFeedGUIDsParams struct {
+	FeedID uuid.UUID
+	Guids  []string
+}
+
+func (q *Queries) GetPostsByFeedGUIDs(ctx context.Context, arg GetPostsByFeedGUIDsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsByFeedGUIDs, arg.FeedID, pq.Array(arg.Guids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.PublishedAtInferred,
+			&i.Content,
+			&i.ArticleHtml,
+			&i.WordCount,
+			&i.ReadingTimeMinutes,
+			&i.Guid,
+			&i.Author,
+			&i.ContentHash,
+			&i.RevisedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsByURLForUser = `-- name: GetPostsByURLForUser :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.published_at_inferred, posts.content, posts.article_html, posts.word_count, posts.reading_time_minutes, posts.guid, posts.author, posts.content_hash, posts.revised_at FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+WHERE posts.url = $1 AND feed_follows.user_id = $2
+ORDER BY posts.created_at DESC
+`
+
+type GetPostsByURLForUserParams struct {
+	Url    string
+	UserID uuid.UUID
+}
+
+// Feeds can share a link, so a URL may match a post in each feed the user follows
+func (q *Queries) GetPostsByURLForUser(ctx context.Context, arg GetPostsByURLForUserParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsByURLForUser, arg.Url, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.PublishedAtInferred,
+			&i.Content,
+			&i.ArticleHtml,
+			&i.WordCount,
+			&i.ReadingTimeMinutes,
+			&i.Guid,
+			&i.Author,
+			&i.ContentHash,
+			&i.RevisedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name AS feed_name 
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.published_at_inferred, posts.content, posts.article_html, posts.word_count, posts.reading_time_minutes, posts.guid, posts.author, posts.content_hash, posts.revised_at, feeds.name AS feed_name 
 FROM posts
 INNER JOIN feeds ON posts.feed_id = feeds.id
 INNER JOIN feed_follows ON feeds.id = feed_follows.feed_id
@@ -71,15 +273,24 @@ type GetPostsForUserParams struct {
 }
 
 type GetPostsForUserRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
-	FeedName    string
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.UUID
+	PublishedAtInferred bool
+	Content             sql.NullString
+	ArticleHtml         sql.NullString
+	WordCount           int32
+	ReadingTimeMinutes  int32
+	Guid                string
+	Author              sql.NullString
+	ContentHash         string
+	RevisedAt           sql.NullTime
+	FeedName            string
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
@@ -100,6 +311,15 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.PublishedAtInferred,
+			&i.Content,
+			&i.ArticleHtml,
+			&i.WordCount,
+			&i.ReadingTimeMinutes,
+			&i.Guid,
+			&i.Author,
+			&i.ContentHash,
+			&i.RevisedAt,
 			&i.FeedName,
 		); err != nil {
 			return nil, err
@@ -118,7 +338,9 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 const getPostsForUserSorted = `-- name: GetPostsForUserSorted :many
 SELECT 
     p.id, p.created_at, p.updated_at, p.title, p.url,
-    p.description, p.published_at, p.feed_id,
+    p.description, p.published_at, p.feed_id, p.published_at_inferred,
+    p.content, p.article_html, p.word_count, p.reading_time_minutes, p.guid, p.author,
+    p.content_hash, p.revised_at,
     f.name AS feed_name
 FROM posts p
 JOIN feed_follows ff ON p.feed_id = ff.feed_id
@@ -166,15 +388,24 @@ type GetPostsForUserSortedParams struct {
 }
 
 type GetPostsForUserSortedRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
-	FeedName    string
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Title               string
+	Url                 string
+	Description         sql.NullString
+	PublishedAt         sql.NullTime
+	FeedID              uuid.UUID
+	PublishedAtInferred bool
+	Content             sql.NullString
+	ArticleHtml         sql.NullString
+	WordCount           int32
+	ReadingTimeMinutes  int32
+	Guid                string
+	Author              sql.NullString
+	ContentHash         string
+	RevisedAt           sql.NullTime
+	FeedName            string
 }
 
 func (q *Queries) GetPostsForUserSorted(ctx context.Context, arg GetPostsForUserSortedParams) ([]GetPostsForUserSortedRow, error) {
@@ -201,6 +432,15 @@ func (q *Queries) GetPostsForUserSorted(ctx context.Context, arg GetPostsForUser
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.PublishedAtInferred,
+			&i.Content,
+			&i.ArticleHtml,
+			&i.WordCount,
+			&i.ReadingTimeMinutes,
+			&i.Guid,
+			&i.Author,
+			&i.ContentHash,
+			&i.RevisedAt,
 			&i.FeedName,
 		); err != nil {
 			return nil, err
@@ -216,6 +456,99 @@ func (q *Queries) GetPostsForUserSorted(ctx context.Context, arg GetPostsForUser
 	return items, nil
 }
 
+const getRecentPublishDates = `-- name: GetRecentPublishDates :many
+SELECT published_at
+FROM posts
+WHERE feed_id = $1
+  AND published_at IS NOT NULL
+  AND NOT published_at_inferred
+ORDER BY published_at DESC
+LIMIT $2
+`
+
+type GetRecentPublishDatesParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentPublishDates(ctx context.Context, arg GetRecentPublishDatesParams) ([]sql.NullTime, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPublishDates, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullTime
+	for rows.Next() {
+		var published_at sql.NullTime
+		if err := rows.Scan(&published_at); err != nil {
+			return nil, err
+		}
+		items = append(items, published_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveFeedPosts = `-- name: MoveFeedPosts :exec
+UPDATE posts
+SET feed_id = $2, updated_at = NOW()
+WHERE feed_id = $1
+  AND guid NOT IN (SELECT guid FROM posts WHERE feed_id = $2)
+`
+
+type MoveFeedPostsParams struct {
+	FeedID   uuid.UUID
+	FeedID_2 uuid.UUID
+}
+
+func (q *Queries) MoveFeedPosts(ctx context.Context, arg MoveFeedPostsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedPosts, arg.FeedID, arg.FeedID_2)
+	return err
+}
+
+const revisePost = `-- name: RevisePost :exec
+UPDATE posts
+SET
+    title = $2,
+    description = $3,
+    content = $4,
+    content_hash = $5,
+    word_count = $6,
+    reading_time_minutes = $7,
+    article_html = NULL,
+    revised_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type RevisePostParams struct {
+	ID                 uuid.UUID
+	Title              string
+	Description        sql.NullString
+	Content            sql.NullString
+	ContentHash        string
+	WordCount          int32
+	ReadingTimeMinutes int32
+}
+
+func (q *Queries) RevisePost(ctx context.Context, arg RevisePostParams) error {
+	_, err := q.db.ExecContext(ctx, revisePost,
+		arg.ID,
+		arg.Title,
+		arg.Description,
+		arg.Content,
+		arg.ContentHash,
+		arg.WordCount,
+		arg.ReadingTimeMinutes,
+	)
+	return err
+}
+
 const searchPosts = `-- name: SearchPosts :many
 SELECT p.id, p.created_at, p.updated_at, p.title, p.url, 
        p.description, p.published_at, p.feed_id, f.name as feed_name
@@ -282,3 +615,46 @@ func (q *Queries) SearchPosts(ctx context.Context, arg SearchPostsParams) ([]Sea
 	}
 	return items, nil
 }
+
+const updatePostArticle = `-- name: UpdatePostArticle :exec
+UPDATE posts
+SET
+    article_html = $2,
+    word_count = $3,
+    reading_time_minutes = $4,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdatePostArticleParams struct {
+	ID                 uuid.UUID
+	ArticleHtml        sql.NullString
+	WordCount          int32
+	ReadingTimeMinutes int32
+}
+
+func (q *Queries) UpdatePostArticle(ctx context.Context, arg UpdatePostArticleParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostArticle,
+		arg.ID,
+		arg.ArticleHtml,
+		arg.WordCount,
+		arg.ReadingTimeMinutes,
+	)
+	return err
+}
+
+const updatePostContentHash = `-- name: UpdatePostContentHash :exec
+UPDATE posts
+SET content_hash = $2
+WHERE id = $1
+`
+
+type UpdatePostContentHashParams struct {
+	ID          uuid.UUID
+	ContentHash string
+}
+
+func (q *Queries) UpdatePostContentHash(ctx context.Context, arg UpdatePostContentHashParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostContentHash, arg.ID, arg.ContentHash)
+	return err
+}
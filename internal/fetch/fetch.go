@@ -0,0 +1,306 @@
+// Package fetch holds the HTTP policy gator uses for everything it downloads:
+// timeouts, response size limits, per-host politeness, robots.txt and the
+// User-Agent header.
+package fetch
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/netguard"
+)
+
+// DefaultUserAgent identifies gator to the sites it fetches
+const DefaultUserAgent = "gator/1.0 (+https://github.com/eniolaomotee/BlogGator-Go)"
+
+// UserAgent returns the default User-Agent with contact details added, so
+// publishers can reach whoever runs this instance
+func UserAgent(contact string) string {
+	if contact == "" {
+		return DefaultUserAgent
+	}
+	return strings.TrimSuffix(DefaultUserAgent, ")") + "; " + contact + ")"
+}
+
+// ErrTooLarge is returned when a response is bigger than Policy.MaxBodyBytes
+var ErrTooLarge = errors.New("response body too large")
+
+// Policy controls how the Fetcher talks to remote hosts
+type Policy struct {
+	ConnectTimeout     time.Duration // dialing and TLS handshake
+	RequestTimeout     time.Duration // whole request, including reading the body
+	MaxBodyBytes       int64         // largest body ReadAll accepts
+	PerHostConcurrency int           // requests in flight to one host
+	PerHostDelay       time.Duration // minimum time between requests to one host
+	RespectRobots      bool          // skip URLs disallowed by robots.txt
+	UserAgent          string
+
+	// Guard vets every URL, redirect and dialed address. nil allows anything,
+	// which is only meant for tests against local servers.
+	Guard *netguard.Guard
+}
+
+// DefaultPolicy returns the limits used when nothing is configured
+func DefaultPolicy() Policy {
+	return Policy{
+		ConnectTimeout:     10 * time.Second,
+		RequestTimeout:     30 * time.Second,
+		MaxBodyBytes:       10 << 20,
+		PerHostConcurrency: 2,
+		PerHostDelay:       time.Second,
+		UserAgent:          DefaultUserAgent,
+	}
+}
+
+// Fetcher applies a Policy to outgoing requests. It is safe for concurrent use
+// and should be shared so that per-host limits hold across workers.
+type Fetcher struct {
+	policy    Policy
+	base      *http.Transport
+	transport *politeTransport
+}
+
+// New creates a Fetcher, filling in defaults for unset policy fields. A zero
+// PerHostDelay means no delay.
+func New(policy Policy) *Fetcher {
+	defaults := DefaultPolicy()
+	if policy.ConnectTimeout <= 0 {
+		policy.ConnectTimeout = defaults.ConnectTimeout
+	}
+	if policy.RequestTimeout <= 0 {
+		policy.RequestTimeout = defaults.RequestTimeout
+	}
+	if policy.MaxBodyBytes <= 0 {
+		policy.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+	if policy.PerHostConcurrency <= 0 {
+		policy.PerHostConcurrency = defaults.PerHostConcurrency
+	}
+	if policy.PerHostDelay < 0 {
+		policy.PerHostDelay = 0
+	}
+	if policy.UserAgent == "" {
+		policy.UserAgent = defaults.UserAgent
+	}
+
+	dialer := &net.Dialer{Timeout: policy.ConnectTimeout, KeepAlive: 30 * time.Second}
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   policy.ConnectTimeout,
+		ResponseHeaderTimeout: policy.RequestTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+	}
+	if policy.Guard != nil {
+		// A proxy would resolve and dial on our behalf, out of the guard's sight
+		base.Proxy = nil
+		base.DialContext = policy.Guard.DialContext(dialer)
+	}
+
+	return &Fetcher{
+		policy: policy,
+		base:   base,
+		transport: &politeTransport{
+			base:   base,
+			policy: policy,
+			hosts:  &hostTable{hosts: make(map[string]*hostState)},
+		},
+	}
+}
+
+// Policy returns the policy in effect
+func (f *Fetcher) Policy() Policy {
+	return f.policy
+}
+
+// Client returns an HTTP client for feeds and pages, bounded by the request
+// timeout. checkRedirect may be nil.
+func (f *Fetcher) Client(checkRedirect func(req *http.Request, via []*http.Request) error) *http.Client {
+	return &http.Client{
+		Transport:     f.transport,
+		CheckRedirect: checkRedirect,
+		Timeout:       f.policy.RequestTimeout,
+	}
+}
+
+// ClientWithTLS is Client for requests that need their own TLS settings, such
+// as a client certificate or a private CA. Per-host limits are still shared.
+func (f *Fetcher) ClientWithTLS(tlsConfig *tls.Config, checkRedirect func(req *http.Request, via []*http.Request) error) *http.Client {
+	if tlsConfig == nil {
+		return f.Client(checkRedirect)
+	}
+
+	base := f.base.Clone()
+	base.TLSClientConfig = tlsConfig
+	// Connections carrying a client certificate aren't kept for other requests
+	base.DisableKeepAlives = true
+
+	return &http.Client{
+		Transport:     &politeTransport{base: base, policy: f.policy, hosts: f.transport.hosts},
+		CheckRedirect: checkRedirect,
+		Timeout:       f.policy.RequestTimeout,
+	}
+}
+
+// Transport returns the polite transport without an overall timeout, for
+// large downloads that legitimately take a while
+func (f *Fetcher) Transport() http.RoundTripper {
+	return f.transport
+}
+
+// ReadAll reads a response body, failing with ErrTooLarge past MaxBodyBytes
+func (f *Fetcher) ReadAll(resp *http.Response) ([]byte, error) {
+	if resp.ContentLength > f.policy.MaxBodyBytes {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.policy.MaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.policy.MaxBodyBytes {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrTooLarge, f.policy.MaxBodyBytes)
+	}
+	return data, nil
+}
+
+// hostState tracks the requests made to a single host
+type hostState struct {
+	slots     chan struct{}
+	mu        sync.Mutex
+	nextStart time.Time
+	robots    *robotsRules
+	// robotsUntil is when the cached rules go stale, and robotsFetch is
+	// closed when an in-flight robots.txt fetch finishes
+	robotsUntil time.Time
+	robotsFetch chan struct{}
+}
+
+// hostTable holds the state of every host contacted so far
+type hostTable struct {
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// politeTransport limits concurrency and request rate per host, checks
+// robots.txt and sets the User-Agent
+type politeTransport struct {
+	base   http.RoundTripper
+	policy Policy
+	hosts  *hostTable
+}
+
+func (t *politeTransport) host(name string) *hostState {
+	t.hosts.mu.Lock()
+	defer t.hosts.mu.Unlock()
+
+	state, ok := t.hosts.hosts[name]
+	if !ok {
+		state = &hostState{slots: make(chan struct{}, t.policy.PerHostConcurrency)}
+		t.hosts.hosts[name] = state
+	}
+	return state
+}
+
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.policy.Guard != nil {
+		if err := t.policy.Guard.CheckURL(req.URL); err != nil {
+			return nil, err
+		}
+	}
+	host := t.host(strings.ToLower(req.URL.Host))
+
+	req = req.Clone(ctx)
+	req.Header.Set("User-Agent", t.policy.UserAgent)
+
+	delay := t.policy.PerHostDelay
+	if t.policy.RespectRobots {
+		rules, err := t.robots(ctx, req.URL.Scheme, host, req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+		if rules.unreachable {
+			return nil, fmt.Errorf("robots.txt for %s is unreachable, not fetching %s", req.URL.Host, req.URL)
+		}
+		if !rules.allowed(req.URL.RequestURI()) {
+			return nil, fmt.Errorf("%s is disallowed by robots.txt", req.URL)
+		}
+		delay = max(delay, rules.crawlDelay)
+	}
+
+	if err := host.acquire(ctx, delay); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		<-host.slots
+		return nil, err
+	}
+
+	// Hold the slot until the body has been read and closed
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-host.slots }}
+	return resp, nil
+}
+
+// acquire waits for a free slot on the host and then for the delay since the
+// previous request. The caller frees the slot with <-h.slots.
+func (h *hostState) acquire(ctx context.Context, delay time.Duration) error {
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if err := h.wait(ctx, delay); err != nil {
+		<-h.slots
+		return err
+	}
+	return nil
+}
+
+// wait blocks until delay has passed since the previous request to the host
+func (h *hostState) wait(ctx context.Context, delay time.Duration) error {
+	h.mu.Lock()
+	now := time.Now()
+	start := now
+	if h.nextStart.After(now) {
+		start = h.nextStart
+	}
+	h.nextStart = start.Add(delay)
+	h.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// releasingBody frees the host slot once the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
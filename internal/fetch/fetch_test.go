@@ -0,0 +1,345 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/netguard"
+)
+
+func TestReadAll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			// No Content-Length, so the limit has to be enforced while reading
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		max     int64
+		wantErr bool
+	}{
+		{name: "under the limit", path: "/", max: 200},
+		{name: "exactly the limit", path: "/", max: 100},
+		{name: "content length over the limit", path: "/", max: 50, wantErr: true},
+		{name: "streamed body over the limit", path: "/?chunked=1", max: 50, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fetcher := New(Policy{MaxBodyBytes: test.max})
+			resp, err := fetcher.Client(nil).Get(server.URL + test.path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+
+			data, err := fetcher.ReadAll(resp)
+			if test.wantErr {
+				if !errors.Is(err, ErrTooLarge) {
+					t.Fatalf("Expected ErrTooLarge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(data) != 100 {
+				t.Fatalf("Expected 100 bytes, got %d", len(data))
+			}
+		})
+	}
+}
+
+func TestPerHostLimits(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	var userAgent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.Header.Get("User-Agent"))
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	fetcher := New(Policy{PerHostConcurrency: 2, PerHostDelay: 10 * time.Millisecond, UserAgent: "test-agent"})
+	client := fetcher.Client(nil)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("Expected at most 2 requests in flight, got %d", got)
+	}
+	// Six request starts spaced 10ms apart take at least 50ms
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("Expected requests to be spaced out, took %s", elapsed)
+	}
+	if got := userAgent.Load(); got != "test-agent" {
+		t.Fatalf("Expected User-Agent %q, got %v", "test-agent", got)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	fetcher := New(Policy{RequestTimeout: 50 * time.Millisecond})
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", server.URL, nil)
+	if _, err := fetcher.Client(nil).Do(req); err == nil {
+		t.Fatalf("Expected a timeout error, got nil")
+	}
+}
+
+func TestRobots(t *testing.T) {
+	robots := `
+# comment
+User-agent: *
+Disallow: /private
+Allow: /private/open
+Crawl-delay: 2
+
+User-agent: gator
+User-agent: other
+Disallow: /feeds/*.xml$
+Disallow:
+`
+
+	tests := []struct {
+		name  string
+		agent string
+		path  string
+		want  bool
+	}{
+		{name: "fallback group disallow", agent: "somebot", path: "/private/page", want: false},
+		{name: "longer allow wins", agent: "somebot", path: "/private/open/page", want: true},
+		{name: "unmatched path", agent: "somebot", path: "/public", want: true},
+		{name: "own group replaces fallback", agent: "gator", path: "/private/page", want: true},
+		{name: "wildcard and anchor", agent: "gator", path: "/feeds/main.xml", want: false},
+		{name: "anchor doesn't match longer path", agent: "gator", path: "/feeds/main.xml?x=1", want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules := parseRobots(strings.NewReader(robots), test.agent)
+			if got := rules.allowed(test.path); got != test.want {
+				t.Fatalf("Expected allowed %v, got %v", test.want, got)
+			}
+		})
+	}
+
+	if rules := parseRobots(strings.NewReader(robots), "somebot"); rules.crawlDelay != 2*time.Second {
+		t.Fatalf("Expected crawl delay 2s, got %s", rules.crawlDelay)
+	}
+	if rules := parseRobots(strings.NewReader(""), "gator"); !rules.allowed("/anything") {
+		t.Fatalf("Expected an empty robots.txt to allow everything")
+	}
+}
+
+func TestRobotsDisallowsRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: gator\nDisallow: /blocked\n"))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := New(Policy{RespectRobots: true})
+	client := fetcher.Client(nil)
+
+	if _, err := client.Get(server.URL + "/blocked/feed.xml"); err == nil {
+		t.Fatalf("Expected robots.txt to block the request")
+	}
+	resp, err := client.Get(server.URL + "/feed.xml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestRobotsFetchWaitsAndIsShared(t *testing.T) {
+	var robotsHits atomic.Int32
+	var mu sync.Mutex
+	var starts []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		if r.URL.Path == "/robots.txt" {
+			robotsHits.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte("User-agent: gator\nDisallow: /blocked\n"))
+		}
+	}))
+	defer server.Close()
+
+	fetcher := New(Policy{RespectRobots: true, PerHostDelay: 30 * time.Millisecond})
+	client := fetcher.Client(nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL + "/blocked/feed.xml")
+			if err == nil {
+				resp.Body.Close()
+				t.Errorf("Expected robots.txt to block the request while it was being fetched")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := robotsHits.Load(); got != 1 {
+		t.Fatalf("Expected robots.txt to be fetched once, got %d", got)
+	}
+
+	resp, err := client.Get(server.URL + "/feed.xml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(starts) != 2 {
+		t.Fatalf("Expected 2 requests to reach the server, got %d", len(starts))
+	}
+	if gap := starts[1].Sub(starts[0]); gap < 30*time.Millisecond {
+		t.Fatalf("Expected the robots.txt fetch to count towards the per-host delay, gap was %s", gap)
+	}
+}
+
+func TestRobotsUnreachable(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		allowed bool
+	}{
+		{name: "server error disallows", status: http.StatusServiceUnavailable, allowed: false},
+		{name: "rate limited disallows", status: http.StatusTooManyRequests, allowed: false},
+		{name: "missing file allows", status: http.StatusNotFound, allowed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var status atomic.Int32
+			status.Store(int32(test.status))
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					w.WriteHeader(int(status.Load()))
+				}
+			}))
+			defer server.Close()
+
+			fetcher := New(Policy{RespectRobots: true})
+			client := fetcher.Client(nil)
+
+			resp, err := client.Get(server.URL + "/feed.xml")
+			if test.allowed {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				resp.Body.Close()
+				return
+			}
+			if err == nil {
+				resp.Body.Close()
+				t.Fatalf("Expected an unreachable robots.txt to block the request")
+			}
+
+			// Once the retry is due and robots.txt is back, the host is allowed again
+			status.Store(http.StatusNotFound)
+			host := fetcher.transport.host(strings.ToLower(strings.TrimPrefix(server.URL, "http://")))
+			host.mu.Lock()
+			if until := time.Until(host.robotsUntil); until > robotsRetry {
+				t.Errorf("Expected a retry within %s, got %s", robotsRetry, until)
+			}
+			host.robotsUntil = time.Now()
+			host.mu.Unlock()
+
+			resp, err = client.Get(server.URL + "/feed.xml")
+			if err != nil {
+				t.Fatalf("Unexpected error after retry: %v", err)
+			}
+			resp.Body.Close()
+		})
+	}
+
+	fetcher := New(Policy{RespectRobots: true, RequestTimeout: time.Second})
+	if _, err := fetcher.Client(nil).Get("http://127.0.0.1:1/feed.xml"); err == nil || !strings.Contains(err.Error(), "robots.txt") {
+		t.Fatalf("Expected a transport error on robots.txt to block the request, got %v", err)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	if got := UserAgent(""); got != DefaultUserAgent {
+		t.Fatalf("Expected %q, got %q", DefaultUserAgent, got)
+	}
+	want := "gator/1.0 (+https://github.com/eniolaomotee/BlogGator-Go; me@example.com)"
+	if got := UserAgent("me@example.com"); got != want {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestGuardChecksRedirects(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed" {
+			// Bounce to the same server by IP, which isn't allowlisted
+			http.Redirect(w, r, server.URL+"/internal", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	guard, err := netguard.New(netguard.Options{Allow: []string{"localhost"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client := New(Policy{Guard: guard}).Client(nil)
+	allowed := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	resp, err := client.Get(allowed + "/internal")
+	if err != nil {
+		t.Fatalf("Expected the allowlisted host to be fetched, got %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Get(allowed + "/feed"); !errors.Is(err, netguard.ErrBlocked) {
+		t.Fatalf("Expected the redirect to be blocked, got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// robotsTTL is how long a host's robots.txt is cached
+	robotsTTL = 24 * time.Hour
+	// robotsRetry is how long an unreachable robots.txt blocks the host
+	// before it is tried again
+	robotsRetry = 5 * time.Minute
+)
+
+// robotsRules are the robots.txt rules that apply to our User-Agent
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	// unreachable means robots.txt couldn't be fetched, which RFC 9309
+	// treats as disallowing everything
+	unreachable bool
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+// allowed reports whether path may be fetched. The longest matching rule
+// wins and Allow wins ties, as in RFC 9309.
+func (r *robotsRules) allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	if r.unreachable {
+		return false
+	}
+
+	allow, length := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > length || (rule.length == length && rule.allow) {
+			allow, length = rule.allow, rule.length
+		}
+	}
+	return allow
+}
+
+// robots returns the cached robots.txt rules for a host, fetching them when
+// stale. Requests arriving while a fetch is in flight wait for its result.
+func (t *politeTransport) robots(ctx context.Context, scheme string, host *hostState, hostname string) (*robotsRules, error) {
+	for {
+		host.mu.Lock()
+		if time.Now().Before(host.robotsUntil) {
+			rules := host.robots
+			host.mu.Unlock()
+			return rules, nil
+		}
+		if done := host.robotsFetch; done != nil {
+			host.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		host.robotsFetch = done
+		host.mu.Unlock()
+
+		rules, ttl := t.fetchRobots(ctx, scheme, host, hostname)
+
+		host.mu.Lock()
+		host.robotsFetch = nil
+		// A fetch cut short by this request's own context says nothing about
+		// the host, so leave it for the next request to try
+		if ctx.Err() == nil {
+			host.robots = rules
+			host.robotsUntil = time.Now().Add(ttl)
+		}
+		host.mu.Unlock()
+		close(done)
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return rules, nil
+	}
+}
+
+// fetchRobots downloads and parses robots.txt, going through the host's slots
+// and delay like any other request. A missing file allows everything, while a
+// transport error or server error disallows everything until robotsRetry has
+// passed. It returns how long the result may be cached.
+func (t *politeTransport) fetchRobots(ctx context.Context, scheme string, host *hostState, hostname string) (*robotsRules, time.Duration) {
+	unreachable := &robotsRules{unreachable: true}
+
+	ctx, cancel := context.WithTimeout(ctx, t.policy.RequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", scheme+"://"+hostname+"/robots.txt", nil)
+	if err != nil {
+		return unreachable, robotsRetry
+	}
+	req.Header.Set("User-Agent", t.policy.UserAgent)
+
+	if err := host.acquire(ctx, t.policy.PerHostDelay); err != nil {
+		return unreachable, robotsRetry
+	}
+	defer func() { <-host.slots }()
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return unreachable, robotsRetry
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return parseRobots(io.LimitReader(resp.Body, 512<<10), productToken(t.policy.UserAgent)), robotsTTL
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return unreachable, robotsRetry
+	default:
+		// Redirects aren't followed, and 4xx means there are no rules
+		return &robotsRules{}, robotsTTL
+	}
+}
+
+// productToken is the name robots.txt groups match against, e.g. "gator" for "gator/1.0 (...)"
+func productToken(userAgent string) string {
+	token, _, _ := strings.Cut(userAgent, "/")
+	token, _, _ = strings.Cut(token, " ")
+	return strings.ToLower(token)
+}
+
+// parseRobots reads the group for agent, falling back to the * group
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	type group struct {
+		agents []string
+		rules  []robotsRule
+		delay  time.Duration
+	}
+
+	var groups []*group
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || (key == "disallow" && value == "") {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: robotsPattern(value),
+			})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.delay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	var fallback *group
+	for _, g := range groups {
+		for _, name := range g.agents {
+			if name == agent {
+				return &robotsRules{rules: g.rules, crawlDelay: g.delay}
+			}
+			if name == "*" && fallback == nil {
+				fallback = g
+			}
+		}
+	}
+	if fallback == nil {
+		return nil
+	}
+	return &robotsRules{rules: fallback.rules, crawlDelay: fallback.delay}
+}
+
+// robotsPattern turns a robots.txt path with * and $ wildcards into a regexp
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+
+	pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(path), `\*`, ".*")
+	if anchored {
+		pattern += "$"
+	}
+	return regexp.MustCompile(pattern)
+}
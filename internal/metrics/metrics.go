@@ -0,0 +1,206 @@
+// Package metrics defines gator's Prometheus metrics: feed fetches, ingested
+// posts, the aggregator's queue, HTTP requests, database queries and service
+// restarts. Handler serves them for scraping.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds gator's metrics plus the Go runtime and process collectors
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Fetch outcomes, the outcome label of the feed fetch metrics
+const (
+	FetchOK          = "ok"
+	FetchNotModified = "not_modified"
+	FetchError       = "error"
+)
+
+// Post sources, the source label of the post metrics
+const (
+	SourcePoll = "poll"
+	SourcePush = "push"
+)
+
+var (
+	FeedFetches = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gator_feed_fetches_total",
+		Help: "Feed fetches by outcome (ok, not_modified, error).",
+	}, []string{"outcome"})
+
+	FeedFetchDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gator_feed_fetch_duration_seconds",
+		Help:    "Time to fetch a feed and store its posts, by outcome.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"outcome"})
+
+	PostsIngested = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gator_posts_ingested_total",
+		Help: "New posts stored, by source (poll, push).",
+	}, []string{"source"})
+
+	PostsUpdated = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gator_posts_updated_total",
+		Help: "Stored posts the publisher edited, by source (poll, push).",
+	}, []string{"source"})
+
+	WorkerQueueDepth = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "gator_worker_queue_depth",
+		Help: "Feeds queued for the aggregator's workers.",
+	})
+
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gator_http_requests_total",
+		Help: "API requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gator_http_request_duration_seconds",
+		Help:    "API request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	DBQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gator_db_query_duration_seconds",
+		Help:    "Database query latency by query name.",
+		Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"query"})
+
+	ServiceRestarts = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "gator_service_restarts_total",
+		Help: "Restarts by the service manager, by service and reason (crash, exit, health_check).",
+	}, []string{"service", "reason"})
+)
+
+// ObserveFetch records one feed fetch
+func ObserveFetch(outcome string, duration time.Duration) {
+	FeedFetches.WithLabelValues(outcome).Inc()
+	FeedFetchDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// ObservePosts records the new and edited posts stored from one document
+func ObservePosts(source string, created, updated int) {
+	PostsIngested.WithLabelValues(source).Add(float64(created))
+	PostsUpdated.WithLabelValues(source).Add(float64(updated))
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// Serve exposes /metrics on addr in the background, for commands that don't
+// run the API. Errors are logged, metrics are never worth stopping for.
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	go func() {
+		log.Printf("Serving metrics on %s/metrics", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics server error: %s", err)
+		}
+	}()
+}
+
+// Middleware records every request under its chi route pattern, so
+// /api/feeds/{feedID}/credentials is one series whatever the id
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// The pattern is only known once chi has routed the request
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// InstrumentDB times every query run through db, labelled with the sqlc
+// query name
+func InstrumentDB(db database.DBTX) database.DBTX {
+	return instrumentedDB{db: db}
+}
+
+// Queries returns instrumented queries on db, a connection pool or a
+// transaction. Use it instead of Queries.WithTx, which would run the
+// transaction's queries without the metrics.
+func Queries(db database.DBTX) *database.Queries {
+	return database.New(InstrumentDB(db))
+}
+
+type instrumentedDB struct {
+	db database.DBTX
+}
+
+func (i instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery(query, time.Now())
+	return i.db.ExecContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+// QueryContext is timed until the first rows are available, not while they are read
+func (i instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observeQuery(query, time.Now())
+	return i.db.QueryContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observeQuery(query, time.Now())
+	return i.db.QueryRowContext(ctx, query, args...)
+}
+
+func observeQuery(query string, start time.Time) {
+	DBQueryDuration.WithLabelValues(queryName(query)).Observe(time.Since(start).Seconds())
+}
+
+// queryName reads the name from the comment sqlc starts every query with,
+// "-- name: GetFeedByID :one"
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "other"
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "other"
+	}
+	return fields[0]
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareRoutePattern(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/api/feeds/{feedID}/credentials", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/api/feeds/1/credentials", "/api/feeds/2/credentials", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	tests := []struct {
+		route  string
+		status string
+		want   float64
+	}{
+		{route: "/api/feeds/{feedID}/credentials", status: "204", want: 2},
+		{route: "unmatched", status: "404", want: 1},
+	}
+	for _, test := range tests {
+		got := testutil.ToFloat64(HTTPRequests.WithLabelValues(http.MethodGet, test.route, test.status))
+		if got != test.want {
+			t.Fatalf("Expected %v requests for %s %s, got %v", test.want, test.route, test.status, got)
+		}
+	}
+}
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "-- name: GetFeedByID :one\nSELECT id FROM feeds WHERE id = $1", want: "GetFeedByID"},
+		{query: "-- name: CreatePosts :many\nINSERT INTO posts", want: "CreatePosts"},
+		{query: "SELECT 1", want: "other"},
+		{query: "-- name: ", want: "other"},
+	}
+
+	for _, test := range tests {
+		if got := queryName(test.query); got != test.want {
+			t.Fatalf("Expected %q for %q, got %q", test.want, test.query, got)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ObserveFetch(FetchNotModified, 200*time.Millisecond)
+	ObservePosts(SourcePush, 3, 1)
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	for _, want := range []string{
+		`gator_feed_fetches_total{outcome="not_modified"} 1`,
+		`gator_posts_ingested_total{source="push"} 3`,
+		`gator_posts_updated_total{source="push"} 1`,
+		"gator_feed_fetch_duration_seconds_bucket",
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("Expected %q in the metrics, got:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package netguard stops user-supplied URLs from reaching internal services.
+// URLs are checked for scheme and port before each request, and resolved
+// addresses are checked again at dial time so DNS tricks and redirects
+// can't reach loopback, private or link-local networks.
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrBlocked is returned for URLs and addresses the guard refuses to fetch
+var ErrBlocked = errors.New("address not allowed")
+
+// DefaultPorts are the ports feeds may be fetched from without an allowlist entry
+var DefaultPorts = []int{80, 443}
+
+// blockedPrefixes are the ranges that aren't on the public internet
+var blockedPrefixes = mustPrefixes(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, including cloud metadata
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved and broadcast
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b::/96",   // NAT64, can map onto private IPv4
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+)
+
+// Options configures a Guard
+type Options struct {
+	// Allow lists hosts and networks that may be fetched even though they are
+	// internal: host names ("feeds.corp.example"), wildcard domains
+	// ("*.corp.example"), IP addresses and CIDR ranges. Allowed hosts may
+	// also use any port.
+	Allow []string
+
+	// Ports that may be used by everyone else, DefaultPorts when empty
+	Ports []int
+}
+
+// Guard checks URLs and dialed addresses
+type Guard struct {
+	hosts    map[string]bool
+	domains  []string // wildcard suffixes, with the leading dot
+	networks []netip.Prefix
+	ports    map[int]bool
+	resolver *net.Resolver
+}
+
+// New builds a Guard, rejecting allowlist entries it can't understand
+func New(opts Options) (*Guard, error) {
+	g := &Guard{
+		hosts:    make(map[string]bool),
+		ports:    make(map[int]bool),
+		resolver: net.DefaultResolver,
+	}
+
+	for _, entry := range opts.Allow {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case strings.HasPrefix(entry, "*."):
+			g.domains = append(g.domains, entry[1:])
+		case strings.Contains(entry, "/"):
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowlist entry %q: %w", entry, err)
+			}
+			g.networks = append(g.networks, prefix.Masked())
+		default:
+			if addr, err := netip.ParseAddr(entry); err == nil {
+				addr = addr.Unmap()
+				g.networks = append(g.networks, netip.PrefixFrom(addr, addr.BitLen()))
+				continue
+			}
+			g.hosts[entry] = true
+		}
+	}
+
+	ports := opts.Ports
+	if len(ports) == 0 {
+		ports = DefaultPorts
+	}
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+		g.ports[port] = true
+	}
+
+	return g, nil
+}
+
+// CheckURL rejects URLs that aren't http(s) on an allowed port, and URLs
+// whose host is a literal blocked address
+func (g *Guard) CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrBlocked, u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrBlocked)
+	}
+
+	addr, err := netip.ParseAddr(host)
+	isIP := err == nil
+	if g.hostAllowed(host) || (isIP && g.addrAllowed(addr)) {
+		return nil
+	}
+
+	port := 80
+	if u.Scheme == "https" {
+		port = 443
+	}
+	if p := u.Port(); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			return fmt.Errorf("%w: port %q", ErrBlocked, p)
+		}
+	}
+	if !g.ports[port] {
+		return fmt.Errorf("%w: port %d", ErrBlocked, port)
+	}
+
+	if isIP && blocked(addr) {
+		return fmt.Errorf("%w: %s", ErrBlocked, addr)
+	}
+	return nil
+}
+
+// DialContext wraps dialer so that it only connects to addresses the guard
+// permits. Host names are resolved here and the checked IP is dialed, so the
+// answer can't change between the check and the connection.
+func (g *Guard) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		host = strings.ToLower(host)
+
+		// Operators have vouched for allowlisted hosts, wherever they resolve
+		if g.hostAllowed(host) {
+			return dialer.DialContext(ctx, network, address)
+		}
+
+		var addrs []netip.Addr
+		if addr, err := netip.ParseAddr(host); err == nil {
+			addrs = []netip.Addr{addr}
+		} else {
+			addrs, err = g.resolver.LookupNetIP(ctx, "ip", host)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var lastErr error = fmt.Errorf("%w: %s has no permitted addresses", ErrBlocked, host)
+		for _, addr := range addrs {
+			addr = addr.Unmap()
+			if blocked(addr) && !g.addrAllowed(addr) {
+				continue
+			}
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+func (g *Guard) hostAllowed(host string) bool {
+	if g.hosts[host] {
+		return true
+	}
+	for _, domain := range g.domains {
+		if strings.HasSuffix(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Guard) addrAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range g.networks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// blocked reports whether addr is outside the public internet
+func blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustPrefixes(ranges ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(ranges))
+	for i, r := range ranges {
+		prefixes[i] = netip.MustParsePrefix(r)
+	}
+	return prefixes
+}
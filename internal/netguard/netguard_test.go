@@ -0,0 +1,133 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	guard, err := New(Options{Allow: []string{"feeds.corp.example", "*.intranet.example", "10.1.0.0/16"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{url: "https://example.com/feed", allowed: true},
+		{url: "http://example.com:80/feed", allowed: true},
+		{url: "http://example.com:8080/feed", allowed: false},
+		{url: "ftp://example.com/feed", allowed: false},
+		{url: "file:///etc/passwd", allowed: false},
+		{url: "http://169.254.169.254/latest/meta-data/", allowed: false},
+		{url: "http://127.0.0.1/", allowed: false},
+		{url: "http://[::1]/", allowed: false},
+		{url: "http://[::ffff:10.0.0.1]/", allowed: false},
+		{url: "http://192.168.1.1/", allowed: false},
+		{url: "http://8.8.8.8/", allowed: true},
+		{url: "http://feeds.corp.example:8080/rss", allowed: true},
+		{url: "http://wiki.intranet.example/rss", allowed: true},
+		{url: "http://10.1.2.3:9000/rss", allowed: true},
+		{url: "http://10.2.0.1/rss", allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			err = guard.CheckURL(u)
+			if test.allowed && err != nil {
+				t.Fatalf("Expected %s to be allowed, got %v", test.url, err)
+			}
+			if !test.allowed && !errors.Is(err, ErrBlocked) {
+				t.Fatalf("Expected %s to be blocked, got %v", test.url, err)
+			}
+		})
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "127.0.0.1", want: true},
+		{addr: "10.0.0.1", want: true},
+		{addr: "172.16.5.4", want: true},
+		{addr: "192.168.0.1", want: true},
+		{addr: "169.254.169.254", want: true},
+		{addr: "100.64.0.1", want: true},
+		{addr: "0.0.0.0", want: true},
+		{addr: "::1", want: true},
+		{addr: "fd00::1", want: true},
+		{addr: "fe80::1", want: true},
+		{addr: "::ffff:127.0.0.1", want: true},
+		{addr: "93.184.216.34", want: false},
+		{addr: "2606:4700::1111", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			if got := blocked(netip.MustParseAddr(test.addr)); got != test.want {
+				t.Fatalf("Expected blocked %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	tests := []struct {
+		name    string
+		allow   []string
+		address string
+		wantErr bool
+	}{
+		{name: "loopback blocked", address: "127.0.0.1:" + port, wantErr: true},
+		{name: "localhost resolves to loopback", address: "localhost:" + port, wantErr: true},
+		{name: "allowlisted network", allow: []string{"127.0.0.0/8"}, address: "127.0.0.1:" + port},
+		{name: "allowlisted host", allow: []string{"localhost"}, address: "localhost:" + port},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			guard, err := New(Options{Allow: test.allow})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			conn, err := guard.DialContext(&net.Dialer{})(context.Background(), "tcp", test.address)
+			if test.wantErr {
+				if !errors.Is(err, ErrBlocked) {
+					t.Fatalf("Expected ErrBlocked, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			conn.Close()
+		})
+	}
+}
+
+func TestNewRejectsBadEntries(t *testing.T) {
+	if _, err := New(Options{Allow: []string{"10.0.0.0/99"}}); err == nil {
+		t.Fatalf("Expected an error for an invalid CIDR")
+	}
+	if _, err := New(Options{Ports: []int{70000}}); err == nil {
+		t.Fatalf("Expected an error for an invalid port")
+	}
+}
@@ -0,0 +1,185 @@
+// Package sanitize cleans up the HTML that feeds put in post bodies. HTML
+// returns markup that is safe to hand to a browser, Text renders it for the
+// terminal.
+package sanitize
+
+import (
+	"html"
+	"net/url"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags lists the elements kept by HTML and the attributes each may carry.
+// Elements that aren't listed are unwrapped, keeping their text.
+var allowedTags = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Caption:    nil,
+	atom.Code:       nil,
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        nil,
+	atom.Li:         nil,
+	atom.Ol:         {"start"},
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.S:          nil,
+	atom.Small:      nil,
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      nil,
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+}
+
+// droppedTags are removed together with everything inside them
+var droppedTags = map[atom.Atom]bool{
+	atom.Applet:   true,
+	atom.Base:     true,
+	atom.Button:   true,
+	atom.Embed:    true,
+	atom.Form:     true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Head:     true,
+	atom.Iframe:   true,
+	atom.Input:    true,
+	atom.Link:     true,
+	atom.Math:     true,
+	atom.Meta:     true,
+	atom.Noscript: true,
+	atom.Object:   true,
+	atom.Script:   true,
+	atom.Select:   true,
+	atom.Style:    true,
+	atom.Svg:      true,
+	atom.Template: true,
+	atom.Textarea: true,
+	atom.Title:    true,
+}
+
+// urlAttrs hold URLs and are only kept when the scheme is safe
+var urlAttrs = map[string]bool{"href": true, "src": true, "cite": true}
+
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// HTML returns the allowlisted subset of fragment. Scripts, styles, event
+// handlers and javascript: URLs are removed and links get rel="nofollow noopener".
+func HTML(fragment string) string {
+	nodes, err := parse(fragment)
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+
+	var s strings.Builder
+	for _, n := range nodes {
+		writeHTML(&s, n)
+	}
+	return strings.TrimSpace(s.String())
+}
+
+func writeHTML(s *strings.Builder, n *xhtml.Node) {
+	switch n.Type {
+	case xhtml.TextNode:
+		s.WriteString(html.EscapeString(n.Data))
+		return
+	case xhtml.ElementNode:
+	default:
+		// Comments, doctypes and the like
+		return
+	}
+
+	if droppedTags[n.DataAtom] {
+		return
+	}
+
+	attrs, allowed := allowedTags[n.DataAtom]
+	if allowed {
+		s.WriteString("<" + n.Data)
+		for _, attr := range n.Attr {
+			if attr.Namespace != "" || !contains(attrs, attr.Key) {
+				continue
+			}
+			if urlAttrs[attr.Key] && !safeURL(attr.Val) {
+				continue
+			}
+			s.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+		}
+		if n.DataAtom == atom.A {
+			s.WriteString(` rel="nofollow noopener noreferrer"`)
+		}
+		s.WriteString(">")
+	}
+
+	if isVoid(n.DataAtom) {
+		return
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeHTML(s, c)
+	}
+
+	if allowed {
+		s.WriteString("</" + n.Data + ">")
+	}
+}
+
+// safeURL reports whether a link target is relative or uses an allowed scheme
+func safeURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || safeSchemes[strings.ToLower(u.Scheme)]
+}
+
+func isVoid(a atom.Atom) bool {
+	return a == atom.Br || a == atom.Hr || a == atom.Img
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// parse parses an HTML fragment as the contents of a <body>
+func parse(fragment string) ([]*xhtml.Node, error) {
+	return xhtml.ParseFragment(strings.NewReader(fragment), &xhtml.Node{
+		Type:     xhtml.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+}
@@ -0,0 +1,114 @@
+package sanitize
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "allowed markup is kept",
+			input: `<p>Hello <strong>world</strong><br></p>`,
+			want:  `<p>Hello <strong>world</strong><br></p>`,
+		},
+		{
+			name:  "script and style are removed with their content",
+			input: `<p>a</p><script>alert(1)</script><style>p{}</style>`,
+			want:  `<p>a</p>`,
+		},
+		{
+			name:  "event handlers and unknown attributes are dropped",
+			input: `<img src="https://example.com/a.png" onerror="alert(1)" style="x" alt="A">`,
+			want:  `<img src="https://example.com/a.png" alt="A">`,
+		},
+		{
+			name:  "javascript links lose their href",
+			input: `<a href="javascript:alert(1)">x</a> <a href="https://example.com">y</a>`,
+			want:  `<a rel="nofollow noopener noreferrer">x</a> <a href="https://example.com" rel="nofollow noopener noreferrer">y</a>`,
+		},
+		{
+			name:  "unknown elements are unwrapped",
+			input: `<center><font color="red">loud</font></center>`,
+			want:  `loud`,
+		},
+		{
+			name:  "text is escaped",
+			input: `1 &lt; 2 &amp; <b>"quoted"</b>`,
+			want:  `1 &lt; 2 &amp; <b>&#34;quoted&#34;</b>`,
+		},
+		{
+			name:  "iframes and comments are removed",
+			input: `<iframe src="https://evil.example"></iframe><!-- hi -->ok`,
+			want:  `ok`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := HTML(test.input)
+			if got != test.want {
+				t.Fatalf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "paragraphs",
+			input: "<p>One\n two</p><p>Three</p>",
+			want:  "One two\n\nThree",
+		},
+		{
+			name:  "links become footnotes",
+			input: `<p>Read <a href="https://a.example">this</a> and <a href="https://b.example">that</a>.</p>`,
+			want:  "Read this[1] and that[2].\n\n[1]: https://a.example\n[2]: https://b.example",
+		},
+		{
+			name:  "bare links are not footnoted",
+			input: `<a href="https://a.example">https://a.example</a>`,
+			want:  "https://a.example",
+		},
+		{
+			name:  "headings, emphasis and code",
+			input: `<h2>Title</h2><p>Some <em>very</em> <b>bold</b> <code>code()</code></p>`,
+			want:  "## Title\n\nSome *very* **bold** `code()`",
+		},
+		{
+			name:  "lists",
+			input: `<ul><li>one</li><li>two<ol><li>nested</li></ol></li></ul><p>after</p>`,
+			want:  "- one\n- two\n  1. nested\n\nafter",
+		},
+		{
+			name:  "blockquote and pre",
+			input: "<blockquote><p>quoted</p></blockquote><pre>line 1\n  line 2</pre>",
+			want:  "> quoted\n\n```\nline 1\n  line 2\n```",
+		},
+		{
+			name:  "images and scripts",
+			input: `<p><img src="a.png" alt="A cat"><script>x()</script></p>`,
+			want:  "[image: A cat]",
+		},
+		{
+			name:  "plain text passes through",
+			input: "just text",
+			want:  "just text",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Text(test.input)
+			if got != test.want {
+				t.Fatalf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
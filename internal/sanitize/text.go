@@ -0,0 +1,301 @@
+package sanitize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// blockTags start a new paragraph
+var blockTags = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Caption: true, atom.Dd: true, atom.Div: true,
+	atom.Dl: true, atom.Dt: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true,
+	atom.Header: true, atom.Main: true, atom.P: true, atom.Section: true, atom.Table: true,
+	atom.Tr: true,
+}
+
+// block is one paragraph of rendered text. Tight blocks, like list items,
+// follow each other without a blank line.
+type block struct {
+	text  string
+	tight bool
+}
+
+type list struct {
+	ordered bool
+	next    int
+}
+
+// textRenderer turns an HTML tree into markdown-flavoured plain text
+type textRenderer struct {
+	blocks []block
+	inline strings.Builder
+	links  []string
+
+	lists      []list
+	marker     string
+	quoteDepth int
+	pre        bool
+}
+
+// Text renders fragment as plain text for the terminal. Headings, lists,
+// quotes and emphasis use markdown syntax, and links are numbered with their
+// URLs collected as footnotes at the end.
+func Text(fragment string) string {
+	nodes, err := parse(fragment)
+	if err != nil {
+		return fragment
+	}
+
+	r := &textRenderer{}
+	for _, n := range nodes {
+		r.walk(n)
+	}
+	r.flush()
+
+	if len(r.links) > 0 {
+		footnotes := make([]string, len(r.links))
+		for i, link := range r.links {
+			footnotes[i] = fmt.Sprintf("[%d]: %s", i+1, link)
+		}
+		r.blocks = append(r.blocks, block{text: strings.Join(footnotes, "\n")})
+	}
+
+	var s strings.Builder
+	for i, b := range r.blocks {
+		if i > 0 {
+			if b.tight && r.blocks[i-1].tight {
+				s.WriteString("\n")
+			} else {
+				s.WriteString("\n\n")
+			}
+		}
+		s.WriteString(b.text)
+	}
+	return s.String()
+}
+
+// WordCount counts the words of readable text in fragment
+func WordCount(fragment string) int {
+	nodes, err := parse(fragment)
+	if err != nil {
+		return len(strings.Fields(fragment))
+	}
+
+	count := 0
+	var walk func(n *xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		if n.Type == xhtml.TextNode {
+			count += len(strings.Fields(n.Data))
+			return
+		}
+		if n.Type == xhtml.ElementNode && droppedTags[n.DataAtom] {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return count
+}
+
+func (r *textRenderer) walk(n *xhtml.Node) {
+	if n.Type == xhtml.TextNode {
+		if r.pre {
+			r.inline.WriteString(n.Data)
+		} else {
+			r.inline.WriteString(strings.ReplaceAll(n.Data, "\n", " "))
+		}
+		return
+	}
+	if n.Type != xhtml.ElementNode || droppedTags[n.DataAtom] {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		r.flush()
+		level, _ := strconv.Atoi(n.Data[1:])
+		r.marker = strings.Repeat("#", level) + " "
+		r.children(n)
+		r.flush()
+
+	case atom.Ul, atom.Ol:
+		r.flush()
+		start := 1
+		if s, err := strconv.Atoi(attr(n, "start")); err == nil {
+			start = s
+		}
+		r.lists = append(r.lists, list{ordered: n.DataAtom == atom.Ol, next: start})
+		r.children(n)
+		r.flush()
+		r.lists = r.lists[:len(r.lists)-1]
+
+	case atom.Li:
+		r.flush()
+		r.marker = "- "
+		if len(r.lists) > 0 {
+			current := &r.lists[len(r.lists)-1]
+			if current.ordered {
+				r.marker = fmt.Sprintf("%d. ", current.next)
+				current.next++
+			}
+		}
+		r.children(n)
+		r.flush()
+
+	case atom.Blockquote:
+		r.flush()
+		r.quoteDepth++
+		r.children(n)
+		r.flush()
+		r.quoteDepth--
+
+	case atom.Pre:
+		r.flush()
+		r.pre = true
+		r.children(n)
+		r.flush()
+		r.pre = false
+
+	case atom.Hr:
+		r.flush()
+		r.add("---", false)
+
+	case atom.Br:
+		r.inline.WriteString("\n")
+
+	case atom.B, atom.Strong:
+		r.wrap(n, "**")
+
+	case atom.I, atom.Em:
+		r.wrap(n, "*")
+
+	case atom.Code:
+		if r.pre {
+			r.children(n)
+		} else {
+			r.wrap(n, "`")
+		}
+
+	case atom.Img:
+		if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+			r.inline.WriteString("[image: " + alt + "]")
+		} else {
+			r.inline.WriteString("[image]")
+		}
+
+	case atom.A:
+		before := r.inline.Len()
+		r.children(n)
+		href := strings.TrimSpace(attr(n, "href"))
+		if href == "" || strings.HasPrefix(href, "#") || !safeURL(href) {
+			return
+		}
+		// Bare links already show their target
+		if strings.TrimSpace(r.inline.String()[before:]) == href {
+			return
+		}
+		r.links = append(r.links, href)
+		r.inline.WriteString(fmt.Sprintf("[%d]", len(r.links)))
+
+	case atom.Td, atom.Th:
+		r.children(n)
+		r.inline.WriteString("  ")
+
+	default:
+		if blockTags[n.DataAtom] {
+			r.flush()
+			r.children(n)
+			r.flush()
+			return
+		}
+		r.children(n)
+	}
+}
+
+func (r *textRenderer) children(n *xhtml.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+// wrap surrounds the element's text with a markdown delimiter
+func (r *textRenderer) wrap(n *xhtml.Node, delim string) {
+	inner := textRenderer{links: r.links, pre: r.pre}
+	inner.children(n)
+	r.links = inner.links
+
+	// Keep the surrounding spaces outside the delimiters
+	raw := inner.inline.String()
+	text := strings.TrimSpace(raw)
+	if text == "" {
+		r.inline.WriteString(raw)
+		return
+	}
+	start := strings.Index(raw, text)
+	r.inline.WriteString(raw[:start] + delim + text + delim + raw[start+len(text):])
+}
+
+// flush ends the current paragraph
+func (r *textRenderer) flush() {
+	raw := r.inline.String()
+	r.inline.Reset()
+
+	var text string
+	if r.pre {
+		code := strings.Trim(raw, "\n")
+		if strings.TrimSpace(code) == "" {
+			return
+		}
+		text = "```\n" + code + "\n```"
+	} else {
+		var lines []string
+		for _, line := range strings.Split(raw, "\n") {
+			if line = strings.Join(strings.Fields(line), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) == 0 {
+			return
+		}
+		text = strings.Join(lines, "\n")
+	}
+
+	tight := false
+	if r.marker != "" {
+		indent := ""
+		if len(r.lists) > 1 {
+			indent = strings.Repeat("  ", len(r.lists)-1)
+		}
+		tight = len(r.lists) > 0
+		text = indent + r.marker + strings.ReplaceAll(text, "\n", "\n"+indent+strings.Repeat(" ", len(r.marker)))
+		r.marker = ""
+	}
+
+	r.add(text, tight)
+}
+
+// add appends a paragraph, quoting it when inside a blockquote
+func (r *textRenderer) add(text string, tight bool) {
+	if r.quoteDepth > 0 {
+		prefix := strings.Repeat("> ", r.quoteDepth)
+		text = prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+	}
+	r.blocks = append(r.blocks, block{text: text, tight: tight})
+}
+
+func attr(n *xhtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,267 @@
+// Package websub implements the subscriber side of WebSub (formerly
+// PubSubHubbub): discovering a feed's hub, subscribing to it and handling the
+// hub's verification requests and content pushes on a callback URL.
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLease is how long subscriptions are requested for; hubs may grant less
+	DefaultLease = 10 * 24 * time.Hour
+
+	// MaxLease caps the lease a hub grants, renewing early is harmless
+	MaxLease = 30 * 24 * time.Hour
+
+	// maxBodyBytes caps a pushed document, like the fetcher caps polled ones
+	maxBodyBytes = 10 << 20
+)
+
+// ErrNotFound is returned by a Store for callbacks it has no subscription for
+var ErrNotFound = errors.New("websub: subscription not found")
+
+// Subscription is what the callback needs to know about one subscription
+type Subscription struct {
+	Topic   string
+	Secret  string // HMAC key sent to the hub, pushes must be signed with it
+	Pending bool   // we asked the hub and are waiting for its verification
+}
+
+// Store keeps the subscriptions behind each callback, identified by id
+type Store interface {
+	Subscription(ctx context.Context, id string) (Subscription, error)
+	// Verified is called when the hub confirms the subscription and grants a lease
+	Verified(ctx context.Context, id string, lease time.Duration) error
+	// Denied is called when the hub refuses the subscription
+	Denied(ctx context.Context, id string, reason string) error
+}
+
+// Handler answers the hub on the callback URL
+type Handler struct {
+	Store Store
+
+	// Deliver ingests a pushed document whose signature checked out
+	Deliver func(ctx context.Context, id string, body []byte, contentType string) error
+}
+
+// ServeCallback handles a hub request for the subscription with the given id:
+// GET for verification of intent, POST for content distribution
+func (h *Handler) ServeCallback(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.verify(w, r, id)
+	case http.MethodPost:
+		h.receive(w, r, id)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) verify(w http.ResponseWriter, r *http.Request, id string) {
+	query := r.URL.Query()
+	mode := query.Get("hub.mode")
+
+	sub, err := h.Store.Subscription(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "couldn't load subscription", http.StatusInternalServerError)
+		return
+	}
+	// Only confirm what we asked for, while we are waiting for the answer.
+	// Anyone can call the callback, so an active subscription can't be changed from here.
+	if query.Get("hub.topic") != sub.Topic || !sub.Pending {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch mode {
+	case "subscribe":
+		challenge := query.Get("hub.challenge")
+		if challenge == "" {
+			http.Error(w, "missing hub.challenge", http.StatusBadRequest)
+			return
+		}
+		lease := DefaultLease
+		if seconds, err := strconv.ParseInt(query.Get("hub.lease_seconds"), 10, 64); err == nil && seconds > 0 {
+			lease = time.Duration(min(seconds, int64(MaxLease/time.Second))) * time.Second
+		}
+		if err := h.Store.Verified(r.Context(), id, lease); err != nil {
+			http.Error(w, "couldn't save subscription", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, challenge)
+
+	case "denied":
+		if err := h.Store.Denied(r.Context(), id, query.Get("hub.reason")); err != nil {
+			http.Error(w, "couldn't save subscription", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		// We never unsubscribe, so nobody gets to confirm it on our behalf
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) receive(w http.ResponseWriter, r *http.Request, id string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	sub, err := h.Store.Subscription(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		// Tell the hub to stop pushing
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, "couldn't load subscription", http.StatusInternalServerError)
+		return
+	}
+
+	// Acknowledged but ignored, so forged pushes can't probe the signature
+	if !ValidSignature(sub.Secret, r.Header.Get("X-Hub-Signature"), body) {
+		log.Printf("websub: ignoring push for %s with a missing or invalid signature", sub.Topic)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if err := h.Deliver(r.Context(), id, body, r.Header.Get("Content-Type")); err != nil {
+		// The hub retries failed deliveries
+		log.Printf("websub: couldn't ingest push for %s: %s", sub.Topic, err)
+		http.Error(w, "couldn't ingest content", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// signatureHashes are the X-Hub-Signature methods the spec allows
+var signatureHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// ValidSignature checks an X-Hub-Signature header ("sha256=<hex>") against the body
+func ValidSignature(secret, header string, body []byte) bool {
+	method, signature, ok := strings.Cut(header, "=")
+	newHash, known := signatureHashes[strings.ToLower(method)]
+	if secret == "" || !ok || !known {
+		return false
+	}
+	want, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+// Sign returns the X-Hub-Signature header a hub sends for body, using sha256
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random secret to share with the hub
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Subscribe asks the hub to push the topic's updates to callback. The hub
+// confirms asynchronously, with a verification request to the callback.
+func Subscribe(ctx context.Context, client *http.Client, hub, topic, callback, secret string, lease time.Duration) error {
+	form := url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {topic},
+		"hub.callback": {callback},
+		"hub.secret":   {secret},
+	}
+	if lease > 0 {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(lease/time.Second)))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hub answered %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// DiscoverLinks reads the hub and self (topic) URLs from HTTP Link headers,
+// which take precedence over links in the feed itself
+func DiscoverLinks(header http.Header) (hub, self string) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok {
+				continue
+			}
+			target = strings.TrimSpace(target)
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.ToLower(strings.Trim(value, `"`))) {
+					if rel == "hub" && hub == "" {
+						hub = target
+					}
+					if rel == "self" && self == "" {
+						self = target
+					}
+				}
+			}
+		}
+	}
+	return hub, self
+}
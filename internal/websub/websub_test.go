@@ -0,0 +1,326 @@
+package websub
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memStore struct {
+	mu        sync.Mutex
+	subs      map[string]Subscription
+	leases    map[string]time.Duration
+	denied    map[string]string
+	delivered map[string][]string
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		subs:      make(map[string]Subscription),
+		leases:    make(map[string]time.Duration),
+		denied:    make(map[string]string),
+		delivered: make(map[string][]string),
+	}
+}
+
+func (m *memStore) Subscription(ctx context.Context, id string) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, ok := m.subs[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	return sub, nil
+}
+
+func (m *memStore) Verified(ctx context.Context, id string, lease time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.leases[id] = lease
+	sub := m.subs[id]
+	sub.Pending = false
+	m.subs[id] = sub
+	return nil
+}
+
+func (m *memStore) Denied(ctx context.Context, id string, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.denied[id] = reason
+	return nil
+}
+
+func (m *memStore) deliver(ctx context.Context, id string, body []byte, contentType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delivered[id] = append(m.delivered[id], string(body))
+	return nil
+}
+
+// newCallback serves the handler at /callback/{id}
+func newCallback(store *memStore) *httptest.Server {
+	handler := &Handler{Store: store, Deliver: store.deliver}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeCallback(w, r, strings.TrimPrefix(r.URL.Path, "/callback/"))
+	}))
+}
+
+// publish pushes body to a callback the way a hub does
+func publish(t *testing.T, callback, signature, body string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, callback, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/atom+xml")
+	if signature != "" {
+		req.Header.Set("X-Hub-Signature", signature)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error pushing to callback: %s", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSubscribeEndToEnd(t *testing.T) {
+	const topic = "https://blog.example.com/feed.atom"
+	const entry = `<feed xmlns="http://www.w3.org/2005/Atom"><entry><id>1</id></entry></feed>`
+
+	store := newMemStore()
+	store.subs["feed-1"] = Subscription{Topic: topic, Secret: "s3cret", Pending: true}
+	callback := newCallback(store)
+	defer callback.Close()
+
+	// A stand-in hub that verifies the intent before accepting, then publishes
+	var hubErr string
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("hub.mode") != "subscribe" || r.Form.Get("hub.topic") != topic || r.Form.Get("hub.secret") != "s3cret" {
+			hubErr = "unexpected subscribe request: " + r.Form.Encode()
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		verify := r.Form.Get("hub.callback") + "?" + url.Values{
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {topic},
+			"hub.challenge":     {"challenge-123"},
+			"hub.lease_seconds": {"3600"},
+		}.Encode()
+		resp, err := http.Get(verify)
+		if err != nil {
+			hubErr = err.Error()
+			return
+		}
+		echoed, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(echoed) != "challenge-123" {
+			hubErr = "verification failed: " + resp.Status + " " + string(echoed)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+
+	err := Subscribe(context.Background(), hub.Client(), hub.URL, topic, callback.URL+"/callback/feed-1", "s3cret", DefaultLease)
+	if err != nil {
+		t.Fatalf("Unexpected error subscribing: %s", err)
+	}
+	if hubErr != "" {
+		t.Fatalf("Hub error: %s", hubErr)
+	}
+	if store.leases["feed-1"] != time.Hour {
+		t.Fatalf("Expected a lease of 1h, got %s", store.leases["feed-1"])
+	}
+
+	if status := publish(t, callback.URL+"/callback/feed-1", Sign("s3cret", []byte(entry)), entry); status != http.StatusNoContent {
+		t.Fatalf("Expected %d for a signed push, got %d", http.StatusNoContent, status)
+	}
+	if len(store.delivered["feed-1"]) != 1 || store.delivered["feed-1"][0] != entry {
+		t.Fatalf("Expected the push to be delivered, got %v", store.delivered["feed-1"])
+	}
+
+	// Forged and unsigned pushes are acknowledged but dropped
+	for _, signature := range []string{Sign("guess", []byte(entry)), ""} {
+		if status := publish(t, callback.URL+"/callback/feed-1", signature, entry); status != http.StatusAccepted {
+			t.Fatalf("Expected %d for a bad signature, got %d", http.StatusAccepted, status)
+		}
+	}
+	if len(store.delivered["feed-1"]) != 1 {
+		t.Fatalf("Expected badly signed pushes to be ignored, got %d deliveries", len(store.delivered["feed-1"]))
+	}
+
+	if status := publish(t, callback.URL+"/callback/unknown", Sign("s3cret", []byte(entry)), entry); status != http.StatusGone {
+		t.Fatalf("Expected %d for an unknown subscription, got %d", http.StatusGone, status)
+	}
+}
+
+func TestSubscribeHubError(t *testing.T) {
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "topic not allowed", http.StatusForbidden)
+	}))
+	defer hub.Close()
+
+	err := Subscribe(context.Background(), hub.Client(), hub.URL, "https://example.com/feed", "https://gator.example.com/cb", "s", 0)
+	if err == nil || !strings.Contains(err.Error(), "topic not allowed") {
+		t.Fatalf("Expected the hub's error, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	store := newMemStore()
+	store.subs["feed-1"] = Subscription{Topic: "https://example.com/feed", Secret: "s", Pending: true}
+	store.subs["feed-3"] = Subscription{Topic: "https://example.com/feed", Secret: "s"}
+	callback := newCallback(store)
+	defer callback.Close()
+
+	tests := []struct {
+		name       string
+		id         string
+		query      url.Values
+		wantStatus int
+	}{
+		{name: "other topic", id: "feed-1", query: url.Values{"hub.mode": {"subscribe"}, "hub.topic": {"https://evil.example/feed"}, "hub.challenge": {"x"}}, wantStatus: http.StatusNotFound},
+		{name: "unknown subscription", id: "feed-2", query: url.Values{"hub.mode": {"subscribe"}, "hub.topic": {"https://example.com/feed"}, "hub.challenge": {"x"}}, wantStatus: http.StatusNotFound},
+		{name: "unsubscribe we didn't ask for", id: "feed-1", query: url.Values{"hub.mode": {"unsubscribe"}, "hub.topic": {"https://example.com/feed"}, "hub.challenge": {"x"}}, wantStatus: http.StatusNotFound},
+		{name: "not waiting for the hub", id: "feed-3", query: url.Values{"hub.mode": {"subscribe"}, "hub.topic": {"https://example.com/feed"}, "hub.challenge": {"x"}, "hub.lease_seconds": {"999999999"}}, wantStatus: http.StatusNotFound},
+		{name: "denial while not waiting", id: "feed-3", query: url.Values{"hub.mode": {"denied"}, "hub.topic": {"https://example.com/feed"}}, wantStatus: http.StatusNotFound},
+		{name: "missing challenge", id: "feed-1", query: url.Values{"hub.mode": {"subscribe"}, "hub.topic": {"https://example.com/feed"}}, wantStatus: http.StatusBadRequest},
+		{name: "denied", id: "feed-1", query: url.Values{"hub.mode": {"denied"}, "hub.topic": {"https://example.com/feed"}, "hub.reason": {"no thanks"}}, wantStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := http.Get(callback.URL + "/callback/" + test.id + "?" + test.query.Encode())
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.wantStatus {
+				t.Fatalf("Expected status %d, got %d", test.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+
+	if _, ok := store.denied["feed-3"]; ok {
+		t.Fatalf("Expected no denial of an active subscription")
+	}
+	if store.denied["feed-1"] != "no thanks" {
+		t.Fatalf("Expected the denial to be recorded, got %q", store.denied["feed-1"])
+	}
+	if len(store.leases) != 0 {
+		t.Fatalf("Expected no subscription to be verified, got %v", store.leases)
+	}
+}
+
+func TestVerifyOnce(t *testing.T) {
+	store := newMemStore()
+	store.subs["feed-1"] = Subscription{Topic: "https://example.com/feed", Secret: "s", Pending: true}
+	callback := newCallback(store)
+	defer callback.Close()
+
+	verify := func(lease string) int {
+		query := url.Values{
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {"https://example.com/feed"},
+			"hub.challenge":     {"x"},
+			"hub.lease_seconds": {lease},
+		}
+		resp, err := http.Get(callback.URL + "/callback/feed-1?" + query.Encode())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// An oversized lease is capped rather than overflowing
+	if status := verify("9223372036854775807"); status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, status)
+	}
+	if store.leases["feed-1"] != MaxLease {
+		t.Fatalf("Expected the lease to be capped at %s, got %s", MaxLease, store.leases["feed-1"])
+	}
+
+	// A forged second verification can't extend the active subscription
+	if status := verify("60"); status != http.StatusNotFound {
+		t.Fatalf("Expected status %d for a verification we didn't ask for, got %d", http.StatusNotFound, status)
+	}
+	if store.leases["feed-1"] != MaxLease {
+		t.Fatalf("Expected the lease to be unchanged, got %s", store.leases["feed-1"])
+	}
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte("<feed/>")
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		want   bool
+	}{
+		{name: "sha256", secret: "s", header: Sign("s", body), want: true},
+		{name: "upper case method", secret: "s", header: "SHA256=" + strings.TrimPrefix(Sign("s", body), "sha256="), want: true},
+		{name: "wrong secret", secret: "other", header: Sign("s", body), want: false},
+		{name: "unknown method", secret: "s", header: "md5=abc", want: false},
+		{name: "not hex", secret: "s", header: "sha256=zz", want: false},
+		{name: "missing", secret: "s", header: "", want: false},
+		{name: "no secret", secret: "", header: Sign("", body), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ValidSignature(test.secret, test.header, body); got != test.want {
+				t.Fatalf("Expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDiscoverLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		links    []string
+		wantHub  string
+		wantSelf string
+	}{
+		{
+			name:     "one header",
+			links:    []string{`<https://hub.example.com/>; rel="hub", <https://blog.example.com/feed>; rel="self"`},
+			wantHub:  "https://hub.example.com/",
+			wantSelf: "https://blog.example.com/feed",
+		},
+		{
+			name:     "separate headers, unquoted rel",
+			links:    []string{`<https://blog.example.com/feed>; rel=self`, `<https://hub.example.com/>; rel=hub`},
+			wantHub:  "https://hub.example.com/",
+			wantSelf: "https://blog.example.com/feed",
+		},
+		{
+			name:    "several rels, first hub wins",
+			links:   []string{`<https://a.example.com/>; rel="hub alternate"`, `<https://b.example.com/>; rel="hub"`},
+			wantHub: "https://a.example.com/",
+		},
+		{
+			name:  "other links",
+			links: []string{`<https://blog.example.com/page/2>; rel="next"`, `garbage`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, link := range test.links {
+				header.Add("Link", link)
+			}
+			hub, self := DiscoverLinks(header)
+			if hub != test.wantHub || self != test.wantSelf {
+				t.Fatalf("Expected %q and %q, got %q and %q", test.wantHub, test.wantSelf, hub, self)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"log"
 	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
 )
 
 type HealthCheck func(ctx context.Context) error
@@ -77,6 +79,8 @@ func (h *HealthChecker) check() {
 			time.Sleep(2 * time.Second)
 			if err := h.service.start(); err != nil {
 				log.Printf("[%s] Error restarting service: %v", h.service.config.Name, err)
+			} else {
+				metrics.ServiceRestarts.WithLabelValues(h.service.config.Name, "health_check").Inc()
 			}
 		}
 	} else {
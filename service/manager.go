@@ -10,6 +10,8 @@ import (
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/eniolaomotee/BlogGator-Go/internal/metrics"
 )
 
 type ServiceStatus string
@@ -311,7 +313,9 @@ func (s *Service) monitor() {
 	runtime := time.Since(s.startTime)
 
 	// check if this was a crash (service died quickly)
+	reason := "exit"
 	if runtime < s.config.CrashThreshold {
+		reason = "crash"
 		s.restartCount++
 		log.Printf("[%s] Service crashed after %v (restart count:%d/%d)", s.config.Name, runtime, s.restartCount, s.config.MaxRestarts)
 	} else {
@@ -379,6 +383,7 @@ func (s *Service) monitor() {
 	s.status = StatusRunning
 	s.startTime = time.Now()
 	log.Printf("[%s] Service restarted (PID: %d)", s.config.Name, s.cmd.Process.Pid)
+	metrics.ServiceRestarts.WithLabelValues(s.config.Name, reason).Inc()
 
 	// Continue monitoring
 	go s.monitor()
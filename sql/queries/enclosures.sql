@@ -0,0 +1,37 @@
+-- name: CreateEnclosure :exec
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, mime_type, length_bytes, duration_seconds)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (post_id, url) DO NOTHING;
+
+
+-- name: GetEnclosuresForPosts :many
+SELECT * FROM enclosures
+WHERE post_id = ANY(@post_ids::uuid[])
+ORDER BY created_at;
+
+
+-- name: GetEpisodesForUser :many
+-- The newest per_feed episodes of each followed feed. A post with several
+-- enclosures is one episode.
+SELECT
+    id, created_at, updated_at, post_id, url,
+    mime_type, length_bytes, duration_seconds,
+    post_title, published_at, feed_id, feed_name
+FROM (
+    SELECT
+        e.id, e.created_at, e.updated_at, e.post_id, e.url,
+        e.mime_type, e.length_bytes, e.duration_seconds,
+        p.title AS post_title,
+        p.published_at,
+        f.id AS feed_id,
+        f.name AS feed_name,
+        DENSE_RANK() OVER (PARTITION BY f.id ORDER BY p.published_at DESC NULLS LAST, p.id) AS episode
+    FROM enclosures e
+    JOIN posts p ON e.post_id = p.id
+    JOIN feeds f ON p.feed_id = f.id
+    JOIN feed_follows ff ON f.id = ff.feed_id
+    WHERE ff.user_id = @user_id
+      AND (@feed_name::text = '' OR f.name ILIKE '%' || @feed_name::text || '%')
+) episodes
+WHERE episode <= @per_feed::int
+ORDER BY feed_name, feed_id, published_at DESC NULLS LAST;
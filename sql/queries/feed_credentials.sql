@@ -0,0 +1,20 @@
+-- name: DeleteFeedCredentials :exec
+DELETE FROM feed_credentials
+WHERE feed_id = $1;
+
+
+-- name: FeedHasCredentials :one
+SELECT EXISTS (SELECT 1 FROM feed_credentials WHERE feed_id = $1);
+
+
+-- name: GetFeedCredentials :one
+SELECT secret FROM feed_credentials
+WHERE feed_id = $1;
+
+
+-- name: UpsertFeedCredentials :exec
+INSERT INTO feed_credentials (feed_id, created_at, updated_at, secret)
+VALUES ($1, NOW(), NOW(), $2)
+ON CONFLICT (feed_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    updated_at = NOW();
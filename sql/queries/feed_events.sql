@@ -0,0 +1,10 @@
+-- name: CreateFeedEvent :exec
+INSERT INTO feed_events (id, created_at, feed_id, event, old_url, new_url, detail)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+
+-- name: GetFeedEvents :many
+SELECT * FROM feed_events
+WHERE feed_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
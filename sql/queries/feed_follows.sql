@@ -1,3 +1,8 @@
+-- name: CountOtherFeedFollowers :one
+SELECT COUNT(*) FROM feed_follows
+WHERE feed_id = $1 AND user_id <> $2;
+
+
 -- name: CreateFeedFollow :one
 WITH inserted_feed_follows AS (
     INSERT INTO feed_follows(user_id, feed_id)
@@ -26,4 +31,11 @@ WHERE feed_follows.user_id = $1;
 
 -- name: DeleteFeedFollowByUserAndFeed :exec
 DELETE FROM feed_follows
-WHERE user_id = $1 AND feed_id = $2;
\ No newline at end of file
+WHERE user_id = $1 AND feed_id = $2;
+
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = $2, updated_at = NOW()
+WHERE feed_id = $1
+  AND user_id NOT IN (SELECT user_id FROM feed_follows WHERE feed_id = $2);
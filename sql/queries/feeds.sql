@@ -20,12 +20,34 @@ JOIN feeds ON users.id = feeds.user_id
 WHERE feeds.id = $1;
 
 
+-- name: GetFeedByID :one
+SELECT * FROM feeds
+WHERE id = $1;
+
+
+-- name: GetFeedForUser :one
+-- A feed the user added or follows
+SELECT feeds.*
+FROM feeds
+WHERE feeds.id = @id
+  AND (feeds.user_id = @user_id
+   OR feeds.id IN (SELECT feed_id FROM feed_follows WHERE feed_follows.user_id = @user_id));
+
 -- name: GetFeedByURL :one
 SELECT * 
 FROM feeds
 WHERE url = $1;
 
 
+-- name: GetFeedByURLForUser :one
+-- A feed the user added or follows, by URL
+SELECT feeds.*
+FROM feeds
+WHERE feeds.url = @url
+  AND (feeds.user_id = @user_id
+   OR feeds.id IN (SELECT feed_id FROM feed_follows WHERE feed_follows.user_id = @user_id));
+
+
 -- name: MarkFeedFetched :exec
 UPDATE feeds
 SET 
@@ -34,8 +56,118 @@ SET
 WHERE id = $1;
 
 
--- name: GetNextFeedToFetch :many
+-- name: ClaimFeedsToFetch :many
+-- Leases the next due feeds to one aggregator instance. SKIP LOCKED lets
+-- instances claim at the same time without waiting on each other, and an
+-- expired lease is up for grabs again. Feeds in exclude aren't claimed.
+UPDATE feeds
+SET
+    lease_owner = @owner::text,
+    lease_expires_at = NOW() + make_interval(secs => @lease_seconds::int)
+WHERE id IN (
+    SELECT due.id
+    FROM feeds due
+    WHERE due.disabled_at IS NULL
+      AND (due.next_fetch_at IS NULL OR due.next_fetch_at <= NOW())
+      AND (due.lease_expires_at IS NULL OR due.lease_expires_at <= NOW())
+      AND due.id <> ALL(@exclude::uuid[])
+    ORDER BY due.next_fetch_at ASC NULLS FIRST, due.last_fetched_at ASC NULLS FIRST
+    LIMIT @batch_size::int
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ClaimFeed :one
+-- Leases one feed for an on-demand refresh, unless an instance is fetching it
+UPDATE feeds
+SET
+    lease_owner = @owner::text,
+    lease_expires_at = NOW() + make_interval(secs => @lease_seconds::int)
+WHERE id = @id
+  AND (lease_expires_at IS NULL OR lease_expires_at <= NOW())
+RETURNING *;
+
+-- name: ReleaseFeedLease :exec
+UPDATE feeds
+SET
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE id = @id AND lease_owner = @owner::text;
+
+-- name: ReleaseFeedLeases :exec
+UPDATE feeds
+SET
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE lease_owner = @owner::text;
+
+-- name: UpdateFeedCacheHeaders :exec
+UPDATE feeds
+SET
+    etag = $2,
+    last_modified = $3,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateFeedSchedule :exec
+UPDATE feeds
+SET
+    next_fetch_at = $2,
+    fetch_interval_seconds = $3,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET
+    consecutive_failures = 0,
+    last_error = NULL,
+    last_status = $2,
+    last_success_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordFeedFailure :one
+UPDATE feeds
+SET
+    consecutive_failures = consecutive_failures + 1,
+    last_error = $2,
+    last_status = $3,
+    next_fetch_at = $4,
+    disabled_at = CASE WHEN consecutive_failures + 1 >= $5::int THEN NOW() ELSE disabled_at END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: EnableFeed :exec
+UPDATE feeds
+SET
+    disabled_at = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetFeedHealthForUser :many
 SELECT feeds.*
-FROM feeds 
-ORDER BY feeds.last_fetched_at ASC NULLS FIRST 
-LIMIT $1;
+FROM feeds
+WHERE feeds.user_id = $1
+   OR feeds.id IN (SELECT feed_id FROM feed_follows WHERE feed_follows.user_id = $1)
+ORDER BY feeds.name;
+
+-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET
+    url = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: DisableFeed :exec
+UPDATE feeds
+SET
+    disabled_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1;
@@ -0,0 +1,16 @@
+-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (id, created_at, post_id, title, description, content, content_hash, changed)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6, $7);
+
+
+-- name: GetPostRevisions :many
+SELECT * FROM post_revisions
+WHERE post_id = $1
+ORDER BY created_at DESC;
+
+
+-- name: GetLatestRevisionsForPosts :many
+SELECT DISTINCT ON (post_id) *
+FROM post_revisions
+WHERE post_id = ANY(@post_ids::uuid[])
+ORDER BY post_id, created_at DESC;
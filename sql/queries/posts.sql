@@ -1,7 +1,28 @@
--- name: CreatePost :one
-INSERT INTO posts (id,created_at, updated_at,title, url, description, published_at, feed_id)
-VALUES($1,$2,$3,$4,$5,$6,$7,$8)
-RETURNING *;
+-- name: AdoptLegacyPostGUIDs :execrows
+-- Posts stored before GUIDs were tracked have their link as GUID. Give them
+-- the item's real GUID, so they are recognised rather than stored again.
+UPDATE posts
+SET guid = i.guid, updated_at = NOW()
+FROM unnest(@guids::text[], @urls::text[]) AS i(guid, url)
+WHERE posts.feed_id = @feed_id::uuid
+  AND posts.guid = posts.url
+  AND posts.url = i.url
+  AND i.guid <> i.url
+  AND NOT EXISTS (SELECT 1 FROM posts known WHERE known.feed_id = @feed_id::uuid AND known.guid = i.guid);
+
+-- name: CreatePosts :many
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_inferred, content, word_count, reading_time_minutes, guid, author, content_hash)
+SELECT
+    i.id, @created_at::timestamp, @created_at::timestamp, i.title, i.url, i.description, i.published_at,
+    @feed_id::uuid, i.published_at_inferred, NULLIF(i.content, ''), i.word_count, i.reading_time_minutes,
+    i.guid, NULLIF(i.author, ''), i.content_hash
+FROM unnest(
+    @ids::uuid[], @titles::text[], @urls::text[], @descriptions::text[], @published_ats::timestamp[],
+    @published_at_inferreds::boolean[], @contents::text[], @word_counts::integer[], @reading_times::integer[],
+    @guids::text[], @authors::text[], @content_hashes::text[]
+) AS i(id, title, url, description, published_at, published_at_inferred, content, word_count, reading_time_minutes, guid, author, content_hash)
+ON CONFLICT (feed_id, guid) DO NOTHING
+RETURNING id, guid;
 
 
 -- name: GetPostsForUser :many
@@ -18,7 +39,9 @@ LIMIT $2;
 -- name: GetPostsForUserSorted :many
 SELECT 
     p.id, p.created_at, p.updated_at, p.title, p.url,
-    p.description, p.published_at, p.feed_id,
+    p.description, p.published_at, p.feed_id, p.published_at_inferred,
+    p.content, p.article_html, p.word_count, p.reading_time_minutes, p.guid, p.author,
+    p.content_hash, p.revised_at,
     f.name AS feed_name
 FROM posts p
 JOIN feed_follows ff ON p.feed_id = ff.feed_id
@@ -57,6 +80,23 @@ LIMIT $2
 OFFSET $5;
 
 
+-- name: GetRecentPublishDates :many
+SELECT published_at
+FROM posts
+WHERE feed_id = $1
+  AND published_at IS NOT NULL
+  AND NOT published_at_inferred
+ORDER BY published_at DESC
+LIMIT $2;
+
+
+-- name: MoveFeedPosts :exec
+UPDATE posts
+SET feed_id = $2, updated_at = NOW()
+WHERE feed_id = $1
+  AND guid NOT IN (SELECT guid FROM posts WHERE feed_id = $2);
+
+
 -- name: SearchPosts :many
 SELECT p.id, p.created_at, p.updated_at, p.title, p.url, 
        p.description, p.published_at, p.feed_id, f.name as feed_name
@@ -70,4 +110,54 @@ WHERE ff.user_id = $1
     OR f.name ILIKE '%' || $2 || '%'
   )
 ORDER BY p.published_at DESC
-LIMIT $3;
\ No newline at end of file
+LIMIT $3;
+
+
+-- name: GetPostsByURLForUser :many
+-- Feeds can share a link, so a URL may match a post in each feed the user follows
+SELECT posts.* FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+WHERE posts.url = $1 AND feed_follows.user_id = $2
+ORDER BY posts.created_at DESC;
+
+
+-- name: UpdatePostArticle :exec
+UPDATE posts
+SET
+    article_html = $2,
+    word_count = $3,
+    reading_time_minutes = $4,
+    updated_at = NOW()
+WHERE id = $1;
+
+
+-- name: GetPostForUser :one
+SELECT posts.* FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+WHERE posts.id = $1 AND feed_follows.user_id = $2;
+
+
+-- name: RevisePost :exec
+UPDATE posts
+SET
+    title = $2,
+    description = $3,
+    content = $4,
+    content_hash = $5,
+    word_count = $6,
+    reading_time_minutes = $7,
+    article_html = NULL,
+    revised_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+
+-- name: UpdatePostContentHash :exec
+UPDATE posts
+SET content_hash = $2
+WHERE id = $1;
+
+
+-- name: GetPostsByFeedGUIDs :many
+SELECT * FROM posts
+WHERE feed_id = @feed_id AND guid = ANY(@guids::text[]);
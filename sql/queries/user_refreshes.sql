@@ -0,0 +1,14 @@
+-- name: TakeRefreshSlot :one
+-- Records a refresh unless the user's last one is more recent than the
+-- cooldown, in which case no row is returned
+INSERT INTO user_refreshes (user_id, refreshed_at)
+VALUES (@user_id, NOW())
+ON CONFLICT (user_id) DO UPDATE SET refreshed_at = NOW()
+WHERE user_refreshes.refreshed_at <= NOW() - make_interval(secs => @cooldown_seconds::int)
+RETURNING refreshed_at;
+
+-- name: GetRefreshWait :one
+-- Seconds until the user may refresh again
+SELECT GREATEST(0, CEIL(EXTRACT(EPOCH FROM refreshed_at + make_interval(secs => @cooldown_seconds::int) - NOW())))::int AS wait_seconds
+FROM user_refreshes
+WHERE user_id = @user_id;
@@ -0,0 +1,40 @@
+-- name: GetWebSubSubscription :one
+SELECT * FROM websub_subscriptions
+WHERE feed_id = $1;
+
+
+-- name: UpsertWebSubSubscription :exec
+INSERT INTO websub_subscriptions (feed_id, created_at, updated_at, hub_url, topic_url, secret, state)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, 'pending')
+ON CONFLICT (feed_id) DO UPDATE
+SET hub_url = EXCLUDED.hub_url,
+    topic_url = EXCLUDED.topic_url,
+    secret = EXCLUDED.secret,
+    state = 'pending',
+    last_error = NULL,
+    updated_at = NOW();
+
+
+-- name: ActivateWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = 'active',
+    lease_expires_at = NOW() + make_interval(secs => @lease_seconds::int),
+    last_error = NULL,
+    updated_at = NOW()
+WHERE feed_id = @feed_id AND state = 'pending';
+
+
+-- name: SetWebSubSubscriptionState :exec
+UPDATE websub_subscriptions
+SET state = $2,
+    last_error = $3,
+    updated_at = NOW()
+WHERE feed_id = $1;
+
+
+-- name: GetWebSubSubscriptionsToRenew :many
+SELECT * FROM websub_subscriptions
+WHERE ((state = 'active' AND lease_expires_at < NOW() + make_interval(secs => @renew_within_seconds::int))
+    OR (state <> 'active' AND updated_at < NOW() - make_interval(secs => @retry_after_seconds::int)))
+  AND feed_id IN (SELECT id FROM feeds WHERE disabled_at IS NULL)
+ORDER BY updated_at ASC;
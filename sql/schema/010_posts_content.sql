@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content TEXT;
+ALTER TABLE posts ADD COLUMN article_html TEXT;
+ALTER TABLE posts ADD COLUMN word_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN reading_time_minutes INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN reading_time_minutes;
+ALTER TABLE posts DROP COLUMN word_count;
+ALTER TABLE posts DROP COLUMN article_html;
+ALTER TABLE posts DROP COLUMN content;
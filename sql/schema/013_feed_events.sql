@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE feed_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    old_url TEXT,
+    new_url TEXT,
+    detail TEXT
+);
+
+CREATE INDEX feed_events_feed_id_idx ON feed_events (feed_id, created_at);
+
+-- +goose Down
+DROP TABLE feed_events;
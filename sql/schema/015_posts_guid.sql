@@ -0,0 +1,32 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN guid TEXT;
+ALTER TABLE posts ADD COLUMN author TEXT;
+
+-- Posts stored so far were identified by their link. Feeds with real GUIDs
+-- get them on their next fetch, see AdoptLegacyPostGUIDs.
+UPDATE posts SET guid = url;
+ALTER TABLE posts ALTER COLUMN guid SET NOT NULL;
+
+-- The same item can appear in several feeds, and descriptions are often empty or repeated
+ALTER TABLE posts DROP CONSTRAINT posts_url_key;
+ALTER TABLE posts DROP CONSTRAINT posts_description_key;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_guid_key UNIQUE (feed_id, guid);
+CREATE INDEX posts_url_idx ON posts (url);
+
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_fkey
+    FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_fkey
+    FOREIGN KEY (feed_id) REFERENCES feeds(id);
+
+-- Fails if posts with the same url or description were stored since
+DROP INDEX posts_url_idx;
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_guid_key;
+ALTER TABLE posts ADD CONSTRAINT posts_description_key UNIQUE (description);
+ALTER TABLE posts ADD CONSTRAINT posts_url_key UNIQUE (url);
+
+ALTER TABLE posts DROP COLUMN author;
+ALTER TABLE posts DROP COLUMN guid;
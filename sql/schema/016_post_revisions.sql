@@ -0,0 +1,25 @@
+-- +goose Up
+-- Hash of the title, description and content, to spot items edited by the publisher.
+-- Empty for posts stored before this migration; the scraper hashes them on first sight.
+ALTER TABLE posts ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN revised_at TIMESTAMP;
+
+-- Previous versions of a post. changed lists the fields that differ in the
+-- version that replaced it, e.g. "title,content".
+CREATE TABLE post_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    description TEXT,
+    content TEXT,
+    content_hash TEXT NOT NULL,
+    changed TEXT NOT NULL
+);
+
+CREATE INDEX post_revisions_post_id_idx ON post_revisions (post_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE post_revisions;
+ALTER TABLE posts DROP COLUMN revised_at;
+ALTER TABLE posts DROP COLUMN content_hash;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- WebSub subscriptions, one per feed whose hub we subscribed to.
+-- state is pending until the hub verifies the request, then active until the
+-- lease expires; denied and failed subscriptions are retried later. The
+-- secret is encrypted with the server key, like feed credentials.
+CREATE TABLE websub_subscriptions (
+    feed_id UUID PRIMARY KEY REFERENCES feeds(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    hub_url TEXT NOT NULL,
+    topic_url TEXT NOT NULL,
+    secret BYTEA NOT NULL,
+    state TEXT NOT NULL DEFAULT 'pending',
+    lease_expires_at TIMESTAMP,
+    last_error TEXT
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- A due feed is leased to the aggregator instance that claimed it, so several
+-- instances can run side by side without fetching the same feed. The lease is
+-- released once the feed is rescheduled; an instance that crashes keeps it
+-- until it expires, then the feed can be claimed again.
+ALTER TABLE feeds
+    ADD COLUMN lease_owner TEXT,
+    ADD COLUMN lease_expires_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds
+    DROP COLUMN lease_owner,
+    DROP COLUMN lease_expires_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- One row per fetch attempt, successful or not, kept for fetch_history_days.
+-- status is NULL when no response came back (DNS, timeout, refused address).
+CREATE TABLE feed_fetches (
+    id UUID PRIMARY KEY,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    started_at TIMESTAMP NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    status INTEGER,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    items_seen INTEGER NOT NULL DEFAULT 0,
+    new_posts INTEGER NOT NULL DEFAULT 0,
+    error TEXT
+);
+
+CREATE INDEX feed_fetches_feed_id_idx ON feed_fetches (feed_id, started_at DESC);
+CREATE INDEX feed_fetches_started_at_idx ON feed_fetches (started_at);
+
+-- +goose Down
+DROP TABLE feed_fetches;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- When each user last asked for an on-demand refresh, to rate-limit them
+-- across the CLI and every API server.
+CREATE TABLE user_refreshes (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    refreshed_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE user_refreshes;